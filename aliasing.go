@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// Slicing an Evy array copies the elements, whereas a Go slice expression
+// shares the array it slices. Arrays themselves are shared by reference,
+// so the difference only shows when elements are written through one of
+// a slice and a slice of it and read through the other.
+//
+// Passing a slice of a variable to a function that writes its elements,
+// such as sort.Ints(a[1:]) or a recursive quicksort, is translated by
+// writing the elements back into the variable after the call. Any other
+// write that a slice or the variable it was sliced from would see in Go
+// is diagnosed, as is appending to a slice of a variable, which in Go
+// overwrites the variable's elements when it has the capacity.
+
+// paramWrites holds the slice parameters of the file's functions whose
+// elements the function writes.
+var paramWrites map[*types.Var]bool
+
+// sliceTemps maps slice expressions passed to a writing function to the
+// temporaries holding them, see translateSliceWriteback.
+var sliceTemps map[*ast.SliceExpr]string
+
+// sliceWriters are the library functions writing the elements of their
+// first argument.
+var sliceWriters = map[string]bool{
+	"copy": true, "clear": true,
+	"sort.Ints": true, "sort.Float64s": true, "sort.Strings": true,
+	"sort.Slice": true, "sort.SliceStable": true,
+	"slices.Sort": true, "slices.Reverse": true,
+}
+
+// elementWrite is a write to the elements of the slice held by a variable.
+type elementWrite struct {
+	obj types.Object
+	pos token.Pos
+}
+
+// sliceAlias is a variable holding a slice of another.
+type sliceAlias struct {
+	v, root types.Object
+	pos     token.Pos
+}
+
+// analyzeSlices finds the parameters written through, then diagnoses the
+// writes whose effect on a shared array Evy does not reproduce.
+func analyzeSlices(info *types.Info, file *ast.File) {
+	paramWrites = map[*types.Var]bool{}
+	sliceTemps = map[*ast.SliceExpr]string{}
+	for changed := true; changed; {
+		changed = false
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			for _, w := range elementWrites(info, fn.Body) {
+				if v, ok := w.obj.(*types.Var); ok && !paramWrites[v] && isParam(info, fn, v) {
+					paramWrites[v] = true
+					changed = true
+				}
+			}
+		}
+	}
+	var aliases []sliceAlias
+	alias := func(lhs, rhs ast.Expr) {
+		id, ok := lhs.(*ast.Ident)
+		slice, isSlice := ast.Unparen(rhs).(*ast.SliceExpr)
+		if !ok || !isSlice || isString(info.TypeOf(slice)) {
+			return
+		}
+		if root := rootVar(info, slice.X); root != nil && root != info.ObjectOf(id) {
+			aliases = append(aliases, sliceAlias{info.ObjectOf(id), root, slice.Pos()})
+		}
+	}
+	uses := map[types.Object][]token.Pos{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Ident:
+			if obj := info.Uses[n]; obj != nil {
+				uses[obj] = append(uses[obj], n.Pos())
+			}
+		case *ast.AssignStmt:
+			if len(n.Lhs) == len(n.Rhs) {
+				for i := range n.Lhs {
+					alias(n.Lhs[i], n.Rhs[i])
+					checkAppend(info, n.Lhs[i], n.Rhs[i])
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) == len(n.Values) {
+				for i := range n.Names {
+					alias(n.Names[i], n.Values[i])
+					checkAppend(info, n.Names[i], n.Values[i])
+				}
+			}
+		}
+		return true
+	})
+	// usedAfter reports whether obj is used after pos.
+	usedAfter := func(obj types.Object, pos token.Pos) bool {
+		for _, use := range uses[obj] {
+			if use > pos {
+				return true
+			}
+		}
+		return false
+	}
+	for _, w := range elementWrites(info, file) {
+		for _, a := range aliases {
+			switch {
+			case w.pos < a.pos:
+			case w.obj == a.v && usedAfter(a.root, w.pos):
+				diagnose(w.pos, "%s is a slice of %s (line %d), which this write does not change in Evy, as slicing copies",
+					a.v.Name(), a.root.Name(), fileSet.Position(a.pos).Line)
+			case w.obj == a.root && usedAfter(a.v, w.pos):
+				diagnose(w.pos, "%s is sliced into %s (line %d), which this write does not change in Evy, as slicing copies",
+					a.root.Name(), a.v.Name(), fileSet.Position(a.pos).Line)
+			}
+		}
+	}
+}
+
+// checkAppend diagnoses appending to a slice of a variable other than the
+// one assigned, which Go may do in place.
+func checkAppend(info *types.Info, lhs, rhs ast.Expr) {
+	call, ok := ast.Unparen(rhs).(*ast.CallExpr)
+	if !ok || len(call.Args) < 2 {
+		return
+	}
+	if b, ok := info.Uses[identOf(call.Fun)].(*types.Builtin); !ok || b.Name() != "append" {
+		return
+	}
+	slice, ok := ast.Unparen(call.Args[0]).(*ast.SliceExpr)
+	if !ok {
+		return
+	}
+	if root := rootVar(info, slice.X); root != nil && root != info.ObjectOf(identOf(lhs)) {
+		diagnose(call.Pos(), "appending to a slice of %s overwrites its elements in Go when it has the capacity, but not in Evy", root.Name())
+	}
+}
+
+func identOf(expr ast.Expr) *ast.Ident {
+	id, _ := ast.Unparen(expr).(*ast.Ident)
+	return id
+}
+
+// rootVar returns the variable a slice expression slices, if it is one.
+func rootVar(info *types.Info, x ast.Expr) types.Object {
+	id := identOf(x)
+	if id == nil {
+		return nil
+	}
+	v, ok := info.ObjectOf(id).(*types.Var)
+	if !ok {
+		return nil
+	}
+	switch v.Type().Underlying().(type) {
+	case *types.Slice, *types.Array:
+		return v
+	}
+	return nil
+}
+
+func isParam(info *types.Info, fn *ast.FuncDecl, v *types.Var) bool {
+	params := info.Defs[fn.Name].Type().(*types.Signature).Params()
+	for i := 0; i < params.Len(); i++ {
+		if params.At(i) == v {
+			return true
+		}
+	}
+	return false
+}
+
+// elementWrites returns the writes in node to the elements of slices held
+// by variables, including through slices of them passed to functions
+// writing their argument.
+func elementWrites(info *types.Info, node ast.Node) []elementWrite {
+	var writes []elementWrite
+	write := func(expr ast.Expr, pos token.Pos) {
+		if slice, ok := ast.Unparen(expr).(*ast.SliceExpr); ok {
+			expr = slice.X
+		}
+		if obj := rootVar(info, expr); obj != nil {
+			writes = append(writes, elementWrite{obj, pos})
+		}
+	}
+	index := func(expr ast.Expr) {
+		if ix, ok := ast.Unparen(expr).(*ast.IndexExpr); ok {
+			write(ix.X, expr.Pos())
+		}
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				index(lhs)
+			}
+		case *ast.IncDecStmt:
+			index(n.X)
+		case *ast.CallExpr:
+			for i, arg := range n.Args {
+				if writesArg(info, n, i) {
+					write(arg, n.Pos())
+				}
+			}
+		}
+		return true
+	})
+	return writes
+}
+
+// writesArg reports whether a call writes the elements of its nth
+// argument.
+func writesArg(info *types.Info, call *ast.CallExpr, n int) bool {
+	var obj types.Object
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		obj = info.Uses[fun]
+	case *ast.SelectorExpr:
+		obj = info.Uses[fun.Sel]
+	}
+	switch obj := obj.(type) {
+	case *types.Builtin:
+		return n == 0 && sliceWriters[obj.Name()]
+	case *types.Func:
+		if obj.Pkg() != nil && sliceWriters[obj.Pkg().Path()+"."+obj.Name()] {
+			return n == 0
+		}
+		params := obj.Type().(*types.Signature).Params()
+		return n < params.Len() && paramWrites[params.At(n)]
+	}
+	return false
+}
+
+// translateSliceWriteback translates a call statement passing slices of
+// variables to a function writing them, writing the elements of each
+// back into its variable after the call.
+func translateSliceWriteback(info *types.Info, call *ast.CallExpr) (string, bool) {
+	var before, after []string
+	for i, arg := range call.Args {
+		slice, ok := ast.Unparen(arg).(*ast.SliceExpr)
+		if !ok || !writesArg(info, call, i) || rootVar(info, slice.X) == nil {
+			continue
+		}
+		lo := "0"
+		if slice.Low != nil {
+			lo = translateOperand(info, slice.Low)
+			if !isStable(info, slice.Low, nil) {
+				tmp := newTemp("lo")
+				before = append(before, tmp+" := "+lo)
+				lo = tmp
+			}
+		}
+		tmp := newTemp("slice")
+		before = append(before, tmp+" := "+translateSliceExpr(info, slice))
+		sliceTemps[slice] = tmp
+		writeBack := typedHelper("__write_back", elemType(info, slice), writeBackSource)
+		after = append(after, evyCall(writeBack, translateOperand(info, slice.X), lo, tmp))
+	}
+	if before == nil {
+		return "", false
+	}
+	lines := append(before, translateExpr(info, call))
+	return joinLines(append(lines, after...)), true
+}
+
+func writeBackSource(name, elem string) string {
+	return fmt.Sprintf(`
+func %s xs:[]%s lo:num slice:[]%s
+    for i := range (len slice)
+        xs[lo + i] = slice[i]
+    end
+end`, name, elem, elem)
+}
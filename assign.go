@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// translateAssign translates an assignment or short variable declaration.
+// As in Go, the operands of index expressions on the left and then the
+// values on the right are evaluated before anything is assigned, so a swap
+// goes through temporaries.
+func translateAssign(info *types.Info, s *ast.AssignStmt) string {
+	if op, ok := compoundOps[s.Tok]; ok {
+		return translateCompound(info, s.Pos(), op, s.Lhs[0], s.Rhs[0])
+	}
+	roots := lhsRoots(info, s.Lhs)
+	var lines []string
+	writes := make([]string, len(s.Lhs))
+	for i, lhs := range s.Lhs {
+		prefix, write, _ := stableLhs(info, lhs, roots, false)
+		lines = append(lines, prefix...)
+		writes[i] = write
+	}
+	var values []string
+	if len(s.Lhs) > 1 && len(s.Rhs) == 1 {
+		var prefix []string
+		prefix, values = tupleValues(info, s.Rhs[0])
+		lines = append(lines, prefix...)
+	} else {
+		for n, rhs := range s.Rhs {
+			value := translateValue(info, rhs, info.TypeOf(s.Lhs[n]))
+			if len(s.Rhs) > 1 && !isStable(info, rhs, roots) {
+				tmp := newTemp("v")
+				lines = append(lines, tmp+" := "+value)
+				value = tmp
+			}
+			values = append(values, value)
+		}
+	}
+	for i, lhs := range s.Lhs {
+		var rhs ast.Expr
+		if len(s.Rhs) == len(s.Lhs) {
+			rhs = s.Rhs[i]
+		}
+		lines = append(lines, assignValue(info, s.Tok, lhs, writes[i], values[i], rhs))
+	}
+	return joinLines(lines)
+}
+
+// translateCompound translates lhs op= rhs, and ++ and --, evaluating the
+// operands of lhs once. rhs is nil for ++ and --.
+func translateCompound(info *types.Info, pos token.Pos, op token.Token, lhs, rhs ast.Expr) string {
+	prefix, write, read := stableLhs(info, lhs, nil, true)
+	y := "1"
+	if rhs != nil {
+		y = translateOperand(info, rhs)
+	}
+	diagnoseInexact(info, pos, op, info.TypeOf(lhs), lhs, rhs)
+	return joinLines(append(prefix, write+" = "+translateArith(pos, op, info.TypeOf(lhs), read, y)))
+}
+
+// mapRead returns the Evy expression reading key from the map x, which
+// gives the zero value for a missing key as Go does.
+func mapRead(m *types.Map, x, key string) string {
+	get := typedHelper("__get", evyType(m.Elem()).String(), getSource)
+	return "(" + evyCall(get, x, key, zeroValue(m.Elem())) + ")"
+}
+
+func getSource(name, elem string) string {
+	return fmt.Sprintf(`
+func %s:%s m:{}%s key:string zero:%s
+    if has m key
+        return m[key]
+    end
+    return zero
+end`, name, elem, elem, elem)
+}
+
+// Evy map keys are strings, so a key of another basic type is translated
+// to the string sprint formats it as, and parsed back when ranging over
+// the map. Keys of other types are diagnosed.
+
+// mapKey translates expr used as a key of the map m.
+func mapKey(info *types.Info, m *types.Map, expr ast.Expr) string {
+	return keyString(info, m, expr, translateOperand(info, expr))
+}
+
+// keyString converts k, the translation of expr used as a key of the map
+// m, to a string. Constant keys are converted when translating.
+func keyString(info *types.Info, m *types.Map, expr ast.Expr, k string) string {
+	if isString(m.Key()) {
+		return k
+	}
+	if val := info.Types[expr].Value; val != nil && (val.Kind() == constant.Int || val.Kind() == constant.Bool) {
+		return strconv.Quote(val.ExactString())
+	}
+	if b, ok := m.Key().Underlying().(*types.Basic); ok && (isNumeric(b) || b.Info()&types.IsBoolean != 0) {
+		return "(" + evyCall("sprint", k) + ")"
+	}
+	diagnose(expr.Pos(), "map keys of type %s are not supported", m.Key())
+	return k
+}
+
+// keyValue converts the string k, a key of the map m, back to a value of
+// its key type.
+func keyValue(m *types.Map, k string) string {
+	switch {
+	case isString(m.Key()):
+		return k
+	case isNumeric(m.Key()):
+		return evyCall("str2num", k)
+	}
+	return k + ` == "true"`
+}
+
+// assignValue assigns one translated value. A blank identifier discards it,
+// keeping only a call's side effects.
+func assignValue(info *types.Info, tok token.Token, lhs ast.Expr, write, value string, rhs ast.Expr) string {
+	if isBlank(lhs) {
+		if _, ok := ast.Unparen(rhs).(*ast.CallExpr); ok && rhs != nil {
+			return value
+		}
+		return ""
+	}
+	if id, ok := lhs.(*ast.Ident); ok && tok == token.DEFINE && info.Defs[id] != nil {
+		return declareValue(info, id, value)
+	}
+	return write + " = " + value
+}
+
+// tupleValues evaluates an expression with several results into
+// temporaries and returns the expressions reading each result.
+func tupleValues(info *types.Info, expr ast.Expr) (prefix, values []string) {
+	tuple, _ := info.TypeOf(expr).(*types.Tuple)
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.IndexExpr:
+		ok, v := newTemp("ok"), newTemp("v")
+		m := info.TypeOf(e.X).Underlying().(*types.Map)
+		prefix = []string{
+			fmt.Sprintf("%s := has %s %s", ok, translateOperand(info, e.X), mapKey(info, m, e.Index)),
+			fmt.Sprintf("%s := %s", v, zeroValue(m.Elem())),
+			fmt.Sprintf("if %s\n%s\nend", ok, i(v+" = "+translateLhs(info, e))),
+		}
+		return prefix, []string{v, ok}
+	case *ast.TypeAssertExpr:
+		t := evyType(info.TypeOf(e.Type))
+		if types.IsInterface(info.TypeOf(e.Type)) {
+			diagnose(e.Pos(), "comma-ok type assertion to %s is not supported", info.TypeOf(e.Type))
+		}
+		ok, v := newTemp("ok"), newTemp("v")
+		prefix = []string{
+			fmt.Sprintf("%s := (typeof %s) == %q", ok, translateOperand(info, e.X), t),
+			fmt.Sprintf("%s := %s", v, zeroValue(info.TypeOf(e.Type))),
+			fmt.Sprintf("if %s\n%s\nend", ok, i(v+" = "+translateOperand(info, e.X)+".("+t.String()+")")),
+		}
+		return prefix, []string{v, ok}
+	case *ast.UnaryExpr:
+		tmp := newTemp("r")
+		elem := chanElem(info, e)
+		prefix = []string{fmt.Sprintf("%s := %s %s %s", tmp, useHelper("__recv_ok"), translateOperand(info, e.X), zeroValue(elem))}
+		return prefix, []string{fmt.Sprintf("%s[0].(%s)", tmp, evyType(elem)), tmp + "[1].(bool)"}
+	case *ast.CallExpr:
+		if prefix, values, ok := scanTuple(info, e); ok {
+			return prefix, values
+		}
+	}
+	tmp := newTemp("r")
+	prefix = []string{tmp + " := " + translateExpr(info, expr)}
+	for i := 0; tuple != nil && i < tuple.Len(); i++ {
+		values = append(values, fmt.Sprintf("%s[%d].(%s)", tmp, i, evyType(tuple.At(i).Type())))
+	}
+	return prefix, values
+}
+
+// stableLhs returns the statements evaluating the operands of an assignment
+// target into temporaries, where later evaluation could differ, along with
+// the target to assign to and, if reads is set, the expression reading its
+// current value.
+func stableLhs(info *types.Info, lhs ast.Expr, roots map[types.Object]bool, reads bool) (prefix []string, write, read string) {
+	operand := func(expr ast.Expr, roots map[types.Object]bool) string {
+		s := translateOperand(info, expr)
+		if isStable(info, expr, roots) {
+			return s
+		}
+		tmp := newTemp("v")
+		prefix = append(prefix, tmp+" := "+s)
+		return tmp
+	}
+	switch e := ast.Unparen(lhs).(type) {
+	case *ast.IndexExpr:
+		// The container is read before assigning, but only a call can
+		// change which one it is.
+		x := operand(e.X, nil)
+		index := operand(e.Index, roots)
+		m, isMap := info.TypeOf(e.X).Underlying().(*types.Map)
+		if isMap {
+			index = keyString(info, m, e.Index, index)
+		}
+		s := x + "[" + index + "]"
+		if isMap && reads {
+			return prefix, s, mapRead(m, x, index)
+		}
+		return prefix, s, s
+	case *ast.SelectorExpr:
+		if sel := info.Selections[e]; sel != nil && sel.Kind() == types.FieldVal {
+			s := operand(e.X, nil) + "." + translateIdent(info, e.Sel)
+			return prefix, s, s
+		}
+	}
+	return nil, translateLhs(info, lhs), translateOperand(info, lhs)
+}
+
+// lhsRoots returns the variables an assignment writes to, or writes into.
+func lhsRoots(info *types.Info, lhs []ast.Expr) map[types.Object]bool {
+	roots := map[types.Object]bool{}
+	for _, expr := range lhs {
+		for {
+			switch e := ast.Unparen(expr).(type) {
+			case *ast.IndexExpr:
+				expr = e.X
+				continue
+			case *ast.SelectorExpr:
+				expr = e.X
+				continue
+			case *ast.StarExpr:
+				expr = e.X
+				continue
+			case *ast.Ident:
+				if obj := info.ObjectOf(e); obj != nil {
+					roots[obj] = true
+				}
+			}
+			break
+		}
+	}
+	return roots
+}
+
+// isStable reports whether evaluating expr has no side effects and reads
+// none of the variables in roots, so it may be evaluated at any point of an
+// assignment.
+func isStable(info *types.Info, expr ast.Expr, roots map[types.Object]bool) bool {
+	stable := true
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			if tv, ok := info.Types[n.Fun]; info.Types[n].Value == nil && !(ok && tv.IsType()) {
+				stable = false
+			}
+		case *ast.UnaryExpr:
+			stable = stable && n.Op != token.ARROW
+		case *ast.Ident:
+			stable = stable && !roots[info.ObjectOf(n)]
+		}
+		return stable
+	})
+	return stable
+}
+
+func joinLines(lines []string) string {
+	var kept []string
+	for _, line := range lines {
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
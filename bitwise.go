@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+)
+
+// Evy has no bitwise operators. They are lowered to helpers that treat an
+// integer-valued num as an infinitely sign-extended two's complement
+// number, which matches Go for every value Evy represents exactly.
+
+var bitwiseHelpers = map[token.Token]string{
+	token.AND:     "__and",
+	token.OR:      "__or",
+	token.XOR:     "__xor",
+	token.AND_NOT: "__andnot",
+	token.SHL:     "__shl",
+	token.SHR:     "__shr",
+}
+
+// translateComplement translates ^x. For unsigned types the result is
+// reduced to the width of the type, as Go does.
+func translateComplement(t types.Type, x string) string {
+	s := useHelper("__not") + " " + x
+	if bits, signed := intSize(t); bits > 0 && !signed {
+		return fmt.Sprintf("%s (%s) %d false", useHelper("__wrap"), s, bits)
+	}
+	return s
+}
+
+func init() {
+	addHelpers(map[string]helper{
+		// __bits applies the bitwise operation op bit by bit until only the
+		// sign bits of a and b remain, then applies it to those.
+		"__bits": {src: `
+func __bits:num a:num b:num op:string
+    r := 0
+    bit := 1
+    while !((a == 0 or a == -1) and (b == 0 or b == -1))
+        x := a - 2 * (floor (a / 2))
+        y := b - 2 * (floor (b / 2))
+        if (__bit x y op) == 1
+            r = r + bit
+        end
+        a = floor (a / 2)
+        b = floor (b / 2)
+        bit = bit * 2
+    end
+    if (__bit (-a) (-b) op) == 1
+        r = r - bit
+    end
+    return r
+end
+
+func __bit:num x:num y:num op:string
+    if op == "&"
+        return x * y
+    else if op == "|"
+        return max x y
+    else if op == "^"
+        return (x + y) % 2
+    end
+    return x * (1 - y)
+end`},
+		"__and": {deps: []string{"__bits"}, src: `
+func __and:num a:num b:num
+    return __bits a b "&"
+end`},
+		"__or": {deps: []string{"__bits"}, src: `
+func __or:num a:num b:num
+    return __bits a b "|"
+end`},
+		"__xor": {deps: []string{"__bits"}, src: `
+func __xor:num a:num b:num
+    return __bits a b "^"
+end`},
+		"__andnot": {deps: []string{"__bits"}, src: `
+func __andnot:num a:num b:num
+    return __bits a b "&^"
+end`},
+		"__not": {src: `
+func __not:num a:num
+    return -a - 1
+end`},
+		"__shl": {deps: []string{"__panic"}, src: `
+func __shl:num a:num n:num
+    if n < 0
+        __panic "runtime error: negative shift amount"
+    end
+    return a * (pow 2 n)
+end`},
+		"__shr": {deps: []string{"__panic"}, src: `
+func __shr:num a:num n:num
+    if n < 0
+        __panic "runtime error: negative shift amount"
+    end
+    return floor (a / (pow 2 n))
+end`},
+	})
+}
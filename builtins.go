@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+)
+
+// Slices are Evy arrays, so append concatenates, and make, copy and clear
+// are loops over the elements, generated per element type as the element
+// zero value can be an array or map that must not be shared. Channels are
+// left to translateChanCall.
+
+// translateBuiltinCall translates a call to a Go builtin operating on
+// strings, slices and maps.
+func translateBuiltinCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	if len(call.Args) > 0 && name != "min" && name != "max" {
+		if _, ok := info.TypeOf(call.Args[0]).Underlying().(*types.Chan); ok {
+			return "", false
+		}
+	}
+	switch name {
+	case "len", "cap":
+		return evyCall("len", translateOperand(info, call.Args[0])), true
+	case "append":
+		return translateAppend(info, call), true
+	case "make":
+		return translateMake(info, call), true
+	case "delete":
+		m := info.TypeOf(call.Args[0]).Underlying().(*types.Map)
+		return evyCall("del", translateOperand(info, call.Args[0]), mapKey(info, m, call.Args[1])), true
+	case "copy":
+		if isString(info.TypeOf(call.Args[1])) {
+			diagnose(call.Pos(), "copying a string to a slice is not supported")
+		}
+		return evyCall(typedHelper("__copy", elemType(info, call.Args[0]), copySource), operands(info, call.Args)...), true
+	case "min", "max":
+		if isString(info.TypeOf(call)) {
+			diagnose(call.Pos(), "%s of strings is not supported", name)
+		}
+		args := operands(info, call.Args)
+		s := args[len(args)-1]
+		for n := len(args) - 2; n >= 0; n-- {
+			s = "(" + evyCall(name, args[n], s) + ")"
+		}
+		return s, true
+	case "clear":
+		x := translateOperand(info, call.Args[0])
+		if m, ok := info.TypeOf(call.Args[0]).Underlying().(*types.Map); ok {
+			return evyCall(typedHelper("__clear_map", evyType(m.Elem()).String(), clearMapSource), x), true
+		}
+		elem := info.TypeOf(call.Args[0]).Underlying().(*types.Slice).Elem()
+		return evyCall(typedHelper("__clear", evyType(elem).String(), fillSource(zeroValue(elem))), x), true
+	}
+	return "", false
+}
+
+// translateAppend translates append to the concatenation of the slice and
+// an array of the appended elements, which the caller assigns back.
+func translateAppend(info *types.Info, call *ast.CallExpr) string {
+	t := info.TypeOf(call)
+	x := translateValue(info, call.Args[0], t)
+	if len(call.Args) == 1 {
+		return x
+	}
+	if call.Ellipsis.IsValid() {
+		if isString(info.TypeOf(call.Args[1])) {
+			diagnose(call.Pos(), "appending a string to a slice is not supported")
+		}
+		return x + " + " + translateOperand(info, call.Args[1])
+	}
+	elem := t.Underlying().(*types.Slice).Elem()
+	var elems []string
+	for _, arg := range call.Args[1:] {
+		if isNil(info, arg) {
+			elems = append(elems, zeroValue(elem))
+			continue
+		}
+		elems = append(elems, translateOperand(info, arg))
+	}
+	return x + " + [" + strings.Join(elems, " ") + "]"
+}
+
+// translateMake translates make for slices and maps. A slice is filled
+// with zero values; its capacity is not kept.
+func translateMake(info *types.Info, call *ast.CallExpr) string {
+	switch t := info.TypeOf(call.Args[0]).Underlying().(type) {
+	case *types.Map:
+		return "{}"
+	case *types.Slice:
+		if n := info.Types[call.Args[1]].Value; n != nil && constant.Sign(n) == 0 {
+			return "[]"
+		}
+		return evyCall(typedHelper("__make", evyType(t.Elem()).String(), makeSource(zeroValue(t.Elem()))), translateOperand(info, call.Args[1]))
+	}
+	diagnose(call.Pos(), "make(%s) is not supported", info.TypeOf(call.Args[0]))
+	return "[]"
+}
+
+// makeSource returns the source of a helper making a slice of n zero
+// values, evaluating zero, an Evy literal, for every element.
+func makeSource(zero string) func(name, elem string) string {
+	return func(name, elem string) string {
+		return fmt.Sprintf(`
+func %s:[]%s n:num
+    xs:[]%s
+    for range n
+        xs = xs + [%s]
+    end
+    return xs
+end`, name, elem, elem, zero)
+	}
+}
+
+// fillSource returns the source of a helper setting every element of a
+// slice to zero, an Evy literal.
+func fillSource(zero string) func(name, elem string) string {
+	return func(name, elem string) string {
+		return fmt.Sprintf(`
+func %s xs:[]%s
+    for i := range (len xs)
+        xs[i] = %s
+    end
+end`, name, elem, zero)
+	}
+}
+
+func copySource(name, elem string) string {
+	return fmt.Sprintf(`
+func %s:num dst:[]%s src:[]%s
+    n := min (len dst) (len src)
+    for i := range n
+        dst[i] = src[i]
+    end
+    return n
+end`, name, elem, elem)
+}
+
+// clearMapSource deletes the keys after collecting them, so the map is
+// not changed while it is iterated.
+func clearMapSource(name, elem string) string {
+	return fmt.Sprintf(`
+func %s m:{}%s
+    keys:[]string
+    for k := range m
+        keys = keys + [k]
+    end
+    for k := range keys
+        del m k
+    end
+end`, name, elem)
+}
+
+func init() {
+	for _, name := range []string{"len", "cap", "append", "make", "delete", "copy", "min", "max", "clear"} {
+		builtinCalls[name] = translateBuiltinCall
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// Evy has no nested functions, so every function literal is lifted to a
+// top-level function named after its enclosing function, the way the Go
+// compiler names them (main_func1, main_func2, ...). Variables the literal
+// captures become extra leading parameters, ahead of any variadic one. A
+// captured variable that is assigned inside a closure, or assigned anywhere
+// once a goroutine has captured it, is boxed in a map {v:value} so every
+// function shares it.
+
+type closure struct {
+	lit  *ast.FuncLit
+	name string
+	free []*types.Var
+}
+
+var (
+	// closures holds every function literal in the file, in source order.
+	closures     map[*ast.FuncLit]*closure
+	closureOrder []*closure
+	// closureVars are local variables bound once to a function literal and
+	// only ever called, so calls through them resolve to the lifted name.
+	closureVars map[types.Object]*closure
+	// boxes maps boxed variables to the Evy variable holding the box.
+	boxes map[types.Object]string
+)
+
+func liftClosures(info *types.Info, file *ast.File) {
+	closures = map[*ast.FuncLit]*closure{}
+	closureOrder = nil
+	closureVars = map[types.Object]*closure{}
+	boxes = map[types.Object]string{}
+	taken := map[string]bool{}
+	for _, decl := range file.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+			taken[localName(info.Defs[funcDecl.Name])] = true
+		}
+	}
+	// lift names the literals in node after the function or package-level
+	// variable they appear in.
+	lift := func(node ast.Node, owner types.Object) {
+		count := 0
+		ast.Inspect(node, func(node ast.Node) bool {
+			lit, ok := node.(*ast.FuncLit)
+			if !ok {
+				return true
+			}
+			name := ""
+			for name == "" || taken[name] {
+				count++
+				name = fmt.Sprintf("%s_func%d", localName(owner), count)
+			}
+			taken[name] = true
+			c := &closure{lit: lit, name: name, free: freeVars(info, lit)}
+			closures[lit] = c
+			closureOrder = append(closureOrder, c)
+			return true
+		})
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Body == nil {
+				continue
+			}
+			lift(decl.Body, info.Defs[decl.Name])
+			bindClosureVars(info, decl.Body)
+			boxCaptured(info, decl.Body)
+			boxParams(info, decl.Type.Params)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if vs, ok := spec.(*ast.ValueSpec); ok {
+					for i, value := range vs.Values {
+						lift(value, info.Defs[vs.Names[min(i, len(vs.Names)-1)]])
+					}
+				}
+			}
+		}
+	}
+}
+
+// freeVars returns the local variables a function literal uses but does not
+// declare, in order of first use. Erased sync primitives are left out.
+func freeVars(info *types.Info, lit *ast.FuncLit) []*types.Var {
+	var free []*types.Var
+	seen := map[*types.Var]bool{}
+	ast.Inspect(lit.Body, func(node ast.Node) bool {
+		id, ok := node.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		v, ok := info.Uses[id].(*types.Var)
+		if !ok || v.IsField() || v.Pkg() == nil || v.Parent() == v.Pkg().Scope() || seen[v] {
+			return true
+		}
+		if lit.Pos() <= v.Pos() && v.Pos() < lit.End() || isErasedSync(v.Type()) && syncTypeName(v.Type()) != "Once" {
+			return true
+		}
+		seen[v] = true
+		free = append(free, v)
+		return true
+	})
+	return free
+}
+
+// assignedVars returns the variables node assigns to after declaring them,
+// including those whose address is taken and each Once whose Do sets its
+// flag, see translateSyncCall.
+func assignedVars(info *types.Info, node ast.Node) map[types.Object]bool {
+	assigned := map[types.Object]bool{}
+	mark := func(expr ast.Expr) {
+		if id, ok := ast.Unparen(expr).(*ast.Ident); ok && info.Uses[id] != nil {
+			assigned[info.Uses[id]] = true
+		}
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range s.Lhs {
+				mark(lhs)
+			}
+		case *ast.IncDecStmt:
+			mark(s.X)
+		case *ast.RangeStmt:
+			if s.Tok == token.ASSIGN {
+				mark(s.Key)
+				if s.Value != nil {
+					mark(s.Value)
+				}
+			}
+		case *ast.UnaryExpr:
+			if s.Op == token.AND {
+				mark(s.X)
+			}
+		case *ast.CallExpr:
+			if sel, ok := s.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Do" && syncTypeName(info.TypeOf(sel.X)) == "Once" {
+				mark(sel.X)
+			}
+		}
+		return true
+	})
+	return assigned
+}
+
+// bindClosureVars finds variables defined as a function literal that are
+// never reassigned and only ever called.
+func bindClosureVars(info *types.Info, body *ast.BlockStmt) {
+	assigned := assignedVars(info, body)
+	called := map[*ast.Ident]bool{}
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.CallExpr:
+			if id, ok := n.Fun.(*ast.Ident); ok {
+				called[id] = true
+			}
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE && len(n.Lhs) == 1 && len(n.Rhs) == 1 {
+				if lit, ok := n.Rhs[0].(*ast.FuncLit); ok && info.Defs[n.Lhs[0].(*ast.Ident)] != nil {
+					closureVars[info.Defs[n.Lhs[0].(*ast.Ident)]] = closures[lit]
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) == 1 && len(n.Values) == 1 {
+				if lit, ok := n.Values[0].(*ast.FuncLit); ok {
+					closureVars[info.Defs[n.Names[0]]] = closures[lit]
+				}
+			}
+		}
+		return true
+	})
+	ast.Inspect(body, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok && info.Uses[id] != nil && !called[id] {
+			delete(closureVars, info.Uses[id])
+		}
+		return true
+	})
+	for obj := range assigned {
+		delete(closureVars, obj)
+	}
+}
+
+// boxCaptured boxes the captured variables of body that are assigned in a
+// closure, or anywhere when a goroutine captures them.
+func boxCaptured(info *types.Info, body *ast.BlockStmt) {
+	assigned := assignedVars(info, body)
+	// Goroutines and function values outlive the statement creating them,
+	// so they must see later assignments too.
+	escaping := map[*ast.FuncLit]bool{}
+	called := map[*ast.FuncLit]bool{}
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.GoStmt:
+			if lit, ok := n.Call.Fun.(*ast.FuncLit); ok {
+				escaping[lit] = true
+			}
+		case *ast.CallExpr:
+			if lit, ok := ast.Unparen(n.Fun).(*ast.FuncLit); ok {
+				called[lit] = true
+			}
+		case *ast.FuncLit:
+			if !called[n] && !isBoundClosure(closures[n]) {
+				escaping[n] = true
+			}
+		}
+		return true
+	})
+	for _, c := range closureOrder {
+		if !(body.Pos() <= c.lit.Pos() && c.lit.End() <= body.End()) {
+			continue
+		}
+		inside := assignedVars(info, c.lit.Body)
+		for _, v := range c.free {
+			if inside[v] || (escaping[c.lit] && assigned[v]) {
+				boxes[v] = v.Name()
+			}
+		}
+	}
+	// Parameters and loop variables already have a plain Evy variable, so
+	// their box gets a name of its own.
+	ast.Inspect(body, func(node ast.Node) bool {
+		var ids []ast.Expr
+		switch n := node.(type) {
+		case *ast.FuncLit:
+			for _, field := range n.Type.Params.List {
+				for _, id := range field.Names {
+					ids = append(ids, id)
+				}
+			}
+		case *ast.RangeStmt:
+			ids = append(ids, n.Key, n.Value)
+		case *ast.ForStmt:
+			if init, ok := n.Init.(*ast.AssignStmt); ok {
+				ids = append(ids, init.Lhs...)
+			}
+		}
+		for _, expr := range ids {
+			if id, ok := expr.(*ast.Ident); ok && boxes[info.Defs[id]] != "" {
+				boxes[info.Defs[id]] = "__" + id.Name
+			}
+		}
+		return true
+	})
+}
+
+// boxParams gives the boxed parameters declared in fields a box name of
+// their own.
+func boxParams(info *types.Info, fields *ast.FieldList) {
+	for _, field := range fields.List {
+		for _, id := range field.Names {
+			if obj := info.Defs[id]; boxes[obj] != "" {
+				boxes[obj] = "__" + id.Name
+			}
+		}
+	}
+}
+
+// varRef returns how a variable that does not live in an Evy local of its
+// own name is read: from a task frame, from a box, or both.
+func varRef(obj types.Object) (string, bool) {
+	if obj == nil {
+		return "", false
+	}
+	box, boxed := boxes[obj]
+	t := evyType(obj.Type()).String()
+	if frame != nil {
+		if slot, ok := frame.names[obj]; ok {
+			if boxed {
+				return "__t." + slot + ".({}" + t + ").v", true
+			}
+			return "__t." + slot + ".(" + t + ")", true
+		}
+	}
+	if boxed {
+		return box + ".v", true
+	}
+	return "", false
+}
+
+// declareVar returns the Evy statement declaring a Go variable with value,
+// creating its box when it is boxed.
+func declareVar(info *types.Info, id *ast.Ident, value string) string {
+	obj := info.ObjectOf(id)
+	if box, ok := boxes[obj]; ok {
+		value = "{v:" + value + "}"
+		if frame == nil {
+			return box + " := " + value
+		}
+	}
+	if frame != nil {
+		if slot, ok := frame.names[obj]; ok {
+			return "__t." + slot + " = " + value
+		}
+	}
+	return translateIdent(info, id) + " := " + value
+}
+
+// boxPrefix boxes parameters and loop variables on entry to their scope.
+// A loop variable gets a fresh box each iteration, matching the per
+// iteration variables of Go 1.22.
+func boxPrefix(info *types.Info, ids ...ast.Expr) string {
+	var lines []string
+	for _, expr := range ids {
+		id, ok := expr.(*ast.Ident)
+		if !ok || boxes[info.ObjectOf(id)] == "" {
+			continue
+		}
+		obj := info.ObjectOf(id)
+		if frame != nil && frame.names[obj] != "" {
+			slot := "__t." + frame.names[obj]
+			lines = append(lines, slot+" = {v:"+slot+".("+evyType(obj.Type()).String()+")}")
+			continue
+		}
+		lines = append(lines, boxes[obj]+" := {v:"+localName(obj)+"}")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// captureArgs returns the values passed for a closure's free variables;
+// boxed variables pass the box itself.
+func captureArgs(c *closure) []string {
+	var args []string
+	for _, v := range c.free {
+		switch ref, ok := varRef(v); {
+		case !ok:
+			args = append(args, localName(v))
+		case boxes[v] != "":
+			args = append(args, strings.TrimSuffix(ref, ".v"))
+		default:
+			args = append(args, ref)
+		}
+	}
+	return args
+}
+
+// freeParams declares the parameters a lifted function receives its free
+// variables in.
+func freeParams(c *closure) []string {
+	var params []string
+	for _, v := range c.free {
+		if box := boxes[v]; box != "" {
+			params = append(params, box+":{}"+evyType(v.Type()).String())
+		} else {
+			params = append(params, localName(v)+":"+evyType(v.Type()).String())
+		}
+	}
+	return params
+}
+
+// calledClosure returns the lifted closure a call's function resolves to.
+func calledClosure(info *types.Info, fun ast.Expr) *closure {
+	switch f := ast.Unparen(fun).(type) {
+	case *ast.FuncLit:
+		return closures[f]
+	case *ast.Ident:
+		return closureVars[info.Uses[f]]
+	}
+	return nil
+}
+
+// translateFuncLit translates a function literal used as a value, binding
+// its captured variables.
+func translateFuncLit(info *types.Info, node *ast.FuncLit) string {
+	c := closures[node]
+	return funcValue(c.name, captureArgs(c)...)
+}
+
+// translateClosureCall calls a lifted closure, passing its captured
+// variables ahead of the call's own arguments.
+func translateClosureCall(info *types.Info, call *ast.CallExpr) (string, bool) {
+	c := calledClosure(info, call.Fun)
+	if c == nil {
+		return "", false
+	}
+	parts := append([]string{c.name}, captureArgs(c)...)
+	for _, arg := range call.Args {
+		parts = append(parts, translateOperand(info, arg))
+	}
+	return strings.Join(parts, " "), true
+}
+
+// isClosureBinding reports whether stmt only binds a variable to a closure
+// that is called directly, and so translates to nothing.
+func isClosureBinding(info *types.Info, stmt ast.Stmt) bool {
+	var id *ast.Ident
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		if s.Tok != token.DEFINE || len(s.Lhs) != 1 {
+			return false
+		}
+		id, _ = s.Lhs[0].(*ast.Ident)
+	case *ast.DeclStmt:
+		gen, ok := s.Decl.(*ast.GenDecl)
+		if !ok || len(gen.Specs) != 1 {
+			return false
+		}
+		spec, ok := gen.Specs[0].(*ast.ValueSpec)
+		if !ok || len(spec.Names) != 1 {
+			return false
+		}
+		id = spec.Names[0]
+	}
+	return id != nil && closureVars[info.Defs[id]] != nil
+}
+
+// translateClosure emits the top-level function a literal is lifted to.
+func translateClosure(info *types.Info, c *closure) string {
+	if taskFuncs[c.name] {
+		return translateTaskDecl(info, c.name, c.free, c.lit.Type, c.lit.Body)
+	}
+	sig := info.TypeOf(c.lit).(*types.Signature)
+	params := append(freeParams(c), paramDecls(sig.Params(), sig.Variadic())...)
+	return translateSignature(c.name, params, resultType(sig.Results())) + translateFuncBody(info, c.lit.Type, c.lit.Body)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+	"strconv"
+)
+
+// foldConst returns the Evy literal for an expression with a constant value.
+// Named constants are left to their declaration, see translateValueSpec.
+func foldConst(info *types.Info, expr ast.Expr) (string, bool) {
+	tv, ok := info.Types[expr]
+	if !ok || tv.Value == nil {
+		return "", false
+	}
+	if id, ok := ast.Unparen(expr).(*ast.Ident); ok {
+		if c, ok := info.Uses[id].(*types.Const); ok && c.Pkg() != nil {
+			return "", false
+		}
+	}
+	return typedConstLiteral(expr.Pos(), tv.Type, tv.Value), true
+}
+
+// typedConstLiteral returns the Evy literal for a constant value of type
+// t, diagnosing values an Evy num does not hold exactly. Durations are
+// translated to seconds, see time.go.
+func typedConstLiteral(pos token.Pos, t types.Type, val constant.Value) string {
+	switch val.Kind() {
+	case constant.Int:
+		if _, exact := constant.Float64Val(val); !exact {
+			diagnose(pos, "constant %s cannot be represented exactly by an Evy num", val.ExactString())
+		}
+	case constant.Float:
+		if f, _ := constant.Float64Val(val); math.IsInf(f, 0) {
+			diagnose(pos, "constant %s overflows an Evy num", val)
+		}
+	case constant.Complex:
+		diagnose(pos, "complex constant %s is not supported", val)
+	}
+	if isDuration(t) {
+		val = constant.BinaryOp(val, token.QUO, constant.MakeInt64(1e9))
+	}
+	return constLiteral(val)
+}
+
+// constLiteral returns the Evy literal for a constant value.
+func constLiteral(val constant.Value) string {
+	switch val.Kind() {
+	case constant.Bool:
+		return strconv.FormatBool(constant.BoolVal(val))
+	case constant.String:
+		return strconv.Quote(constant.StringVal(val))
+	case constant.Int:
+		return val.ExactString()
+	case constant.Float:
+		f, _ := constant.Float64Val(val)
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return val.String()
+}
@@ -3,28 +3,38 @@ package main
 import (
 	"bytes"
 	evy "evylang.dev/evy/pkg/parser"
+	"flag"
 	"fmt"
 	"go/ast"
+	"go/constant"
 	"go/importer"
 	"go/parser"
 	"go/token"
 	"go/types"
 	"io/ioutil"
 	"log"
+	"maps"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
 // ... (other imports and your translateNode function remain)
 
 func main() {
-	if len(os.Args) < 2 { // Check for minimum number of arguments
-		fmt.Println("Usage: go run main.go <directory_or_file_path>")
+	cmdOptions.register(flag.CommandLine)
+	flag.Parse()
+	if flag.NArg() < 1 { // Check for minimum number of arguments
+		fmt.Println("Usage: go run main.go [-wrap] [-names keep|snake] [-seed n] [-prompt] <directory_or_file_path>")
+		os.Exit(1)
+	}
+	if err := cmdOptions.apply(); err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	testPath := os.Args[1]
+	testPath := flag.Arg(0)
 
 	fileInfo, err := os.Stat(testPath)
 	if err != nil {
@@ -44,6 +54,63 @@ func main() {
 	}
 }
 
+// options are the flags a file is translated with.
+type options struct {
+	wrap   bool
+	names  string
+	seed   int64
+	prompt bool
+}
+
+// cmdOptions are the flags given on the command line.
+var cmdOptions = options{names: "keep"}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.wrap, "wrap", o.wrap, "wrap sized integer arithmetic and round float32 results")
+	fs.StringVar(&o.names, "names", o.names, "naming style of translated identifiers: keep or snake")
+	fs.Int64Var(&o.seed, "seed", o.seed, "seed a deterministic random number generator instead of Evy's rand")
+	fs.BoolVar(&o.prompt, "prompt", o.prompt, "prompt for the values of flags and os.Args, which default to none")
+}
+
+// apply sets up translating with the options.
+func (o options) apply() error {
+	if o.names != "keep" && o.names != "snake" {
+		return fmt.Errorf("Invalid naming style: %s", o.names)
+	}
+	wrapInts, nameStyle, randSeed, promptFlags = o.wrap, o.names, o.seed, false
+	if o.seed != 0 {
+		seedRand(o.seed)
+	}
+	if o.prompt {
+		promptInput()
+	}
+	return nil
+}
+
+// fileFlagsPrefix starts a comment ahead of the package clause giving the
+// flags a file is translated with in addition to the command line's, such
+// as "// golang2evy: -wrap", so a directory of files needing different
+// flags is translated in one run.
+const fileFlagsPrefix = "golang2evy:"
+
+// fileOptions returns the options file is translated with and whether its
+// header comment gives any.
+func fileOptions(file *ast.File) (options, bool, error) {
+	opts := cmdOptions
+	if file.Doc == nil {
+		return opts, false, nil
+	}
+	for _, c := range file.Doc.List {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if args, ok := strings.CutPrefix(text, fileFlagsPrefix); ok {
+			fs := flag.NewFlagSet(fileFlagsPrefix, flag.ContinueOnError)
+			opts.register(fs)
+			return opts, true, fs.Parse(strings.Fields(args))
+		}
+	}
+	return opts, false, nil
+}
+
 func processFile(testPath, fileName string) {
 	var filePath string
 	if fileName == "" {
@@ -59,11 +126,25 @@ func processFile(testPath, fileName string) {
 	}
 
 	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, "", sourceCode, 0)
+	file, err := parser.ParseFile(fset, filePath, sourceCode, parser.ParseComments)
 	if err != nil {
 		fmt.Println("Error parsing Go code:", err)
 		return
 	}
+	if opts, ok, err := fileOptions(file); ok {
+		if err == nil {
+			err = opts.apply()
+		}
+		if err != nil {
+			fmt.Println("Error in", filePath, "flags:", err)
+			return
+		}
+		saved := maps.Clone(helperDefs)
+		defer func() {
+			helperDefs = saved
+			cmdOptions.apply()
+		}()
+	}
 	conf := types.Config{Importer: importer.Default()}
 
 	// types.TypeOf() requires all three maps are populated
@@ -71,12 +152,15 @@ func processFile(testPath, fileName string) {
 		Defs:  make(map[*ast.Ident]types.Object),
 		Uses:  make(map[*ast.Ident]types.Object),
 		Types: make(map[ast.Expr]types.TypeAndValue),
+
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
 	}
 
-	_, err = conf.Check(filePath, fset, []*ast.File{file}, info)
+	pkg, err := conf.Check(filePath, fset, []*ast.File{file}, info)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	fileSet, filePkg = fset, pkg
 	evyCode := translateNode(info, file)
 	if evyCode == "" {
 		fmt.Println(filePath, "translation empty")
@@ -101,6 +185,17 @@ func processFile(testPath, fileName string) {
 	fmt.Println("Exit code for", evyFilePath, "is", cmd.ProcessState.ExitCode())
 }
 
+// fileSet resolves positions for diagnostics about the file being translated.
+var fileSet *token.FileSet
+
+// filePkg is the package of the file being translated.
+var filePkg *types.Package
+
+// diagnose reports Go code that cannot be translated faithfully.
+func diagnose(pos token.Pos, format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", fileSet.Position(pos), fmt.Sprintf(format, args...))
+}
+
 // translateNode now converts a Go AST node to an Evy AST node
 func translateNode(info *types.Info, goNode ast.Node) string {
 	switch node := goNode.(type) {
@@ -123,11 +218,11 @@ func translateNode(info *types.Info, goNode ast.Node) string {
 	case *ast.BranchStmt:
 		panic(node)
 	case *ast.CallExpr:
-		panic(node)
+		return translateExpr(info, node)
 	case *ast.CaseClause:
 		panic(node)
 	case *ast.ChanType:
-		panic(node)
+		return evyType(info.TypeOf(node)).String()
 	case *ast.CommClause:
 		panic(node)
 	case *ast.CompositeLit:
@@ -159,7 +254,7 @@ func translateNode(info *types.Info, goNode ast.Node) string {
 	case *ast.GenDecl:
 		return translateGenDecl(info, node)
 	case *ast.GoStmt:
-		panic(node)
+		return translateGoStmt(info, node)
 	case *ast.Ident:
 		return translateIdent(info, node)
 	case *ast.IfStmt:
@@ -186,6 +281,8 @@ func translateNode(info *types.Info, goNode ast.Node) string {
 		return translateRangeStmt(info, node)
 	case *ast.ReturnStmt:
 		return translateReturnStmt(info, node)
+	case *ast.SelectStmt:
+		return translateSelectStmt(info, node)
 	case *ast.SelectorExpr:
 		return translateSelectorExpr(info, node)
 	case *ast.SendStmt:
@@ -209,10 +306,20 @@ func translateNode(info *types.Info, goNode ast.Node) string {
 	default:
 		panic("")
 	}
-	panic("")
 }
 
 func translateExprStmt(info *types.Info, node *ast.ExprStmt) string {
+	if s, ok := translateTimerWait(info, node.X); ok {
+		return s
+	}
+	if call, ok := ast.Unparen(node.X).(*ast.CallExpr); ok {
+		if prefix, _, ok := scanTuple(info, call); ok {
+			return joinLines(prefix)
+		}
+		if s, ok := translateSliceWriteback(info, call); ok {
+			return s
+		}
+	}
 	return translateExpr(info, node.X)
 }
 
@@ -230,9 +337,17 @@ func translateBasicLit(info *types.Info, node *ast.BasicLit) string {
 }
 
 func translateExpr(info *types.Info, expr ast.Expr) string {
+	if s, ok := foldConst(info, expr); ok {
+		return s
+	}
 	var buf bytes.Buffer
 	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		return translateIndexExpr(info, e)
 	case *ast.Ident:
+		if s, ok := translateFuncIdent(info, e); ok {
+			return s
+		}
 		buf.WriteString(translateIdent(info, e))
 	case *ast.BasicLit:
 		buf.WriteString(e.Value)
@@ -240,19 +355,80 @@ func translateExpr(info *types.Info, expr ast.Expr) string {
 		return "(" + translateBinaryExpr(info, e) + ")"
 	case *ast.UnaryExpr:
 		return translateUnaryExpr(info, e)
+	case *ast.StarExpr:
+		return translateExpr(info, e.X) // pointers are erased, see evyType
+	case *ast.SliceExpr:
+		return translateSliceExpr(info, e)
 	case *ast.ParenExpr:
 		return translateParenExpr(info, e)
+	case *ast.FuncLit:
+		return translateFuncLit(info, e)
 	case *ast.CallExpr:
-		buf.WriteString(translateExpr(info, e.Fun))
-		buf.WriteString(" ")
-		for i, arg := range e.Args {
-			if i > 0 {
-				buf.WriteString(" ")
+		if temp, ok := recvTemps[e]; ok {
+			return temp // a blocking call hoisted by the task, see hoistRecvs
+		}
+		if s, ok := translateConversion(info, e); ok {
+			return s
+		}
+		if s, ok := translateStdlibCall(info, e); ok {
+			return s
+		}
+		if s, ok := translateErrorCall(info, e); ok {
+			return s
+		}
+		if s, ok := translateChanCall(info, e); ok {
+			return s
+		}
+		if s, ok := translateSyncCall(info, e); ok {
+			return s
+		}
+		if s, ok := translateClosureCall(info, e); ok {
+			return s
+		}
+		if s, ok := translateMethodCall(info, e); ok {
+			return s
+		}
+		if s, ok := translateFuncValueCall(info, e); ok {
+			return s
+		}
+		switch fun := e.Fun.(type) {
+		case *ast.Ident:
+			buf.WriteString(translateIdent(info, fun))
+		case *ast.SelectorExpr:
+			if libraryFunc(info, fun) != nil {
+				buf.WriteString(translateIdent(info, fun.Sel)) // not a function value
+				break
+			}
+			buf.WriteString(translateExpr(info, e.Fun))
+		default:
+			buf.WriteString(translateExpr(info, e.Fun))
+		}
+		sig, _ := info.TypeOf(e.Fun).(*types.Signature)
+		for n, arg := range e.Args {
+			if isErasedSync(info.TypeOf(arg)) {
+				continue
+			}
+			buf.WriteString(" ")
+			if sig != nil && isNil(info, arg) {
+				buf.WriteString(zeroValue(paramType(sig, n)))
+				continue
 			}
-			buf.WriteString(translateExpr(info, arg))
+			buf.WriteString(translateOperand(info, arg))
 		}
 		buf.WriteString("")
 	case *ast.SelectorExpr:
+		if isPkgVar(info, e, "os", "Args") {
+			return "(" + useHelper("__os_args") + ")"
+		}
+		if s, ok := translateMethodSelector(info, e); ok {
+			return s
+		}
+		if s, ok := translateLibraryFuncValue(info, e); ok {
+			return s
+		}
+		if sel := info.Selections[e]; sel != nil && sel.Kind() == types.FieldVal {
+			return translateFieldRead(info, e)
+		}
 		buf.WriteString(translateIdent(info, e.Sel))
 	case *ast.MapType:
 		buf.WriteString("{}") // Use curly braces for maps
@@ -261,7 +437,7 @@ func translateExpr(info *types.Info, expr ast.Expr) string {
 		buf.WriteString("[]")
 		buf.WriteString(translateExpr(info, e.Elt))
 	case *ast.CompositeLit:
-		switch info.TypeOf(e).(type) { // Determine the type of the literal
+		switch t := info.TypeOf(e).Underlying().(type) { // Determine the type of the literal
 		case *types.Slice:
 			// Slice literal
 			buf.WriteString("[")
@@ -281,36 +457,41 @@ func translateExpr(info *types.Info, expr ast.Expr) string {
 					buf.WriteString(" ")
 				}
 				kvExpr := elt.(*ast.KeyValueExpr)
-				buf.WriteString(translateExpr(info, kvExpr.Key)) // Key (string)
+				buf.WriteString(mapKey(info, t, kvExpr.Key)) // Key (string)
 				buf.WriteString(": ")
 				buf.WriteString(translateExpr(info, kvExpr.Value)) // Value
 			}
 			buf.WriteString("}")
 
 		case *types.Array:
-			// Array literal (translate as Evy slice)
-			buf.WriteString("[")
-			for i, elt := range e.Elts {
-				if i > 0 {
-					buf.WriteString(" ")
-				}
-				buf.WriteString(translateExpr(info, elt))
+			// Array literal (translate as Evy slice), padded to its
+			// length with zero values
+			var elems []string
+			for _, elt := range e.Elts {
+				elems = append(elems, translateExpr(info, elt))
 			}
-			buf.WriteString("]")
+			for int64(len(elems)) < t.Len() {
+				elems = append(elems, zeroValue(t.Elem()))
+			}
+			buf.WriteString("[" + strings.Join(elems, " ") + "]")
 
 		case *types.Struct:
-			// Struct literal (translate as Evy map)
-			buf.WriteString("{")
+			// Struct literal (translate as Evy map), with the omitted
+			// fields zeroed
+			values := map[*types.Var]ast.Expr{}
 			for i, elt := range e.Elts {
-				if i > 0 {
-					buf.WriteString(" ")
+				if kvExpr, ok := elt.(*ast.KeyValueExpr); ok {
+					values[info.Uses[kvExpr.Key.(*ast.Ident)].(*types.Var)] = kvExpr.Value
+				} else {
+					values[t.Field(i)] = elt // Positional fields
 				}
-				kvExpr := elt.(*ast.KeyValueExpr)
-				buf.WriteString(translateIdent(info, kvExpr.Key.(*ast.Ident))) // Field name (string)
-				buf.WriteString(": ")
-				buf.WriteString(translateExpr(info, kvExpr.Value)) // Value
 			}
-			buf.WriteString("}")
+			buf.WriteString(structValue(t, func(field *types.Var) string {
+				if value, ok := values[field]; ok {
+					return translateExpr(info, value)
+				}
+				return zeroValue(field.Type())
+			}))
 		}
 		// fill this out
 	// Add cases for other expression types as needed (e.g., *ast.StarExpr,
@@ -357,8 +538,8 @@ func translateFieldList(info *types.Info, node *ast.FieldList) string {
 }
 
 func i(s string) string {
-	s = strings.TrimSpace(s)        // Remove leading/trailing whitespace
-	lines := strings.Split(s, "\n") // Break into lines
+	s = strings.TrimRight(strings.TrimLeft(s, "\n"), " \t\n") // Remove blank lines around the block, keep nested indentation
+	lines := strings.Split(s, "\n")                           // Break into lines
 	for i := range lines {
 		lines[i] = "    " + lines[i] // Add indent to each line
 	}
@@ -369,87 +550,139 @@ func n(s string) string {
 	return strings.TrimSpace(s) + "\n" // Trim whitespace and add newline
 }
 
-func translateForStmt(info *types.Info, node *ast.ForStmt) string {
-	var buf strings.Builder
-	//rangeInit, ok := node.Init.(*ast.AssignStmt)
-	//if ok && len(rangeInit.Lhs) == 1 && len(rangeInit.Rhs) == 1 && rangeInit.Tok == token.DEFINE {
-	//	buf.WriteString("for ")
-	//	if rangeInit.Lhs[0].(*ast.Ident).Name != "_" {
-	//		buf.WriteString(translateExpr(info, rangeInit.Lhs[0]))
-	//		buf.WriteString(" := ")
-	//	}
-	//	buf.WriteString("range ")
-	//	buf.WriteString(translateExpr(info, rangeInit.Rhs[0]))
-	//	buf.WriteString("")
-	//	buf.WriteString("\n")
-	//	buf.WriteString(translateBlockStmt(info, node.Body))
-	//	buf.WriteString("\nend\n")
-	//	return buf.String()
-	//}
-
-	// Check if the for loop can be simplified to a while loop
-	if node.Init == nil && node.Post == nil {
-		buf.WriteString("while ")
-		buf.WriteString(translateExpr(info, node.Cond))
-		buf.WriteString("\n")
-		buf.WriteString(translateBlockStmt(info, node.Body))
-		buf.WriteString("\nend\n")
-		return buf.String()
+// loop is a three-clause for loop counting an integer variable to a bound
+// that does not change in the loop, which Evy runs as a range.
+type loop struct {
+	v     *ast.Ident
+	start ast.Expr
+	cond  *ast.BinaryExpr
+	step  int64
+}
+
+func countedLoop(info *types.Info, node *ast.ForStmt) *loop {
+	init, ok := node.Init.(*ast.AssignStmt)
+	if !ok || init.Tok != token.DEFINE || len(init.Lhs) != 1 || len(init.Rhs) != 1 {
+		return nil
 	}
-	// Translate standard for loop
-	buf.WriteString("for ")
-	// Initialize
-	var assignStmt *ast.AssignStmt // Declare assignStmt here
-	assignStmt, ok := node.Init.(*ast.AssignStmt)
-	if assignStmt != nil {
-		//assignStmt, ok := node.Init.(*ast.AssignStmt) // Assign assignStmt within the if statement
-		if ok {
-			buf.WriteString(translateIdent(info, assignStmt.Lhs[0].(*ast.Ident))) // Assuming the LHS is a single identifier
-			buf.WriteString(" := range")
-		} else {
-			buf.WriteString(translateStmt(info, node.Init)) // Fallback if the Init isn't a simple assignment
-			buf.WriteString("; ")
+	v := init.Lhs[0].(*ast.Ident)
+	obj := info.Defs[v]
+	if obj == nil || !isInteger(obj.Type()) {
+		return nil
+	}
+	cond, ok := node.Cond.(*ast.BinaryExpr)
+	if !ok {
+		return nil
+	}
+	if x, ok := cond.X.(*ast.Ident); !ok || info.Uses[x] != obj {
+		return nil
+	}
+	var step int64
+	switch post := node.Post.(type) {
+	case *ast.IncDecStmt:
+		step = 1
+		if post.Tok == token.DEC {
+			step = -1
+		}
+		if x, ok := post.X.(*ast.Ident); !ok || info.Uses[x] != obj {
+			return nil
+		}
+	case *ast.AssignStmt:
+		if x, ok := post.Lhs[0].(*ast.Ident); !ok || info.Uses[x] != obj {
+			return nil
 		}
+		value := info.Types[post.Rhs[0]].Value
+		if value == nil {
+			return nil
+		}
+		step, ok = constant.Int64Val(constant.ToInt(value))
+		if !ok {
+			return nil
+		}
+		switch post.Tok {
+		case token.SUB_ASSIGN:
+			step = -step
+		case token.ADD_ASSIGN:
+		default:
+			return nil
+		}
+	default:
+		return nil
 	}
+	switch {
+	case (cond.Op == token.LSS || cond.Op == token.LEQ) && step > 0:
+	case (cond.Op == token.GTR || cond.Op == token.GEQ) && step < 0:
+	default:
+		return nil
+	}
+	assigned := assignedVars(info, node.Body)
+	if assigned[obj] || !isStable(info, cond.Y, assigned) {
+		return nil
+	}
+	return &loop{v: v, start: init.Rhs[0], cond: cond, step: step}
+}
 
-	// Condition
-	if node.Cond != nil {
-		binExpr, ok := node.Cond.(*ast.BinaryExpr)
-		if ok && (binExpr.Op == token.LSS || binExpr.Op == token.LEQ) { // Assuming the condition is a simple comparison
-			stopExpr := translateExpr(info, binExpr.Y)
-			if node.Init != nil {
-				startExpr := translateExpr(info, assignStmt.Rhs[0]) // Assuming the RHS of the Init assignment is the start
-				buf.WriteString(" ")
-				buf.WriteString(startExpr)
-				buf.WriteString(" ")
+func translateForStmt(info *types.Info, node *ast.ForStmt) string {
+	var buf strings.Builder
+	if l := countedLoop(info, node); l != nil {
+		stop := translateOperand(info, l.cond.Y)
+		switch l.cond.Op {
+		case token.LEQ:
+			stop = "(" + stop + " + 1)"
+		case token.GEQ:
+			stop = "(" + stop + " - 1)"
+		}
+		if value := info.Types[l.cond.Y].Value; value != nil {
+			bound, _ := constant.Int64Val(constant.ToInt(value))
+			switch l.cond.Op {
+			case token.LEQ:
+				bound++
+			case token.GEQ:
+				bound--
 			}
-
-			buf.WriteString(stopExpr)
-
-			// Step
-			if node.Post != nil {
-				incDecStmt, ok := node.Post.(*ast.IncDecStmt)
-				if ok && incDecStmt.Tok == token.INC {
-					buf.WriteString(" 1") // Add step = 1 if it's a simple ++
-				}
+			stop = strconv.FormatInt(bound, 10)
+			if bound < 0 {
+				stop = "(" + stop + ")"
 			}
-			buf.WriteString(" ")
-		} else {
-			buf.WriteString(translateExpr(info, node.Cond)) // Fallback to standard condition translation
-			buf.WriteString(" ")
 		}
+		start := translateOperand(info, l.start)
+		buf.WriteString("for ")
+		buf.WriteString(translateIdent(info, l.v))
+		buf.WriteString(" := range ")
+		switch {
+		case l.step != 1:
+			fmt.Fprintf(&buf, "%s %s %s", start, stop, translateOperand(info, &ast.BasicLit{Kind: token.INT, Value: strconv.FormatInt(l.step, 10)}))
+		case start == "0":
+			buf.WriteString(stop)
+		default:
+			buf.WriteString(start + " " + stop)
+		}
+		buf.WriteString("\n")
+		if prefix := boxPrefix(info, l.v); prefix != "" {
+			buf.WriteString(i(prefix))
+			buf.WriteString("\n")
+		}
+		writeBlock(&buf, translateBlockStmt(info, node.Body))
+		buf.WriteString("end\n")
+		return buf.String()
+	}
+	// Any other loop runs as a while loop, its init statement declaring
+	// variables in the enclosing scope; see analyzeScopes.
+	if node.Init != nil {
+		buf.WriteString(translateStmt(info, node.Init))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("while ")
+	if node.Cond != nil {
+		buf.WriteString(translateExpr(info, node.Cond))
+	} else {
+		buf.WriteString("true")
 	}
-
-	//// Post (should be empty for your syntax)
-	//if node.Post != nil {
-	//	buf.WriteString(translateStmt(info, node.Post))
-	//}
-
-	// Body of the loop
 	buf.WriteString("\n")
-	buf.WriteString(translateBlockStmt(info, node.Body))
-	buf.WriteString("\nend\n")
-
+	writeBlock(&buf, translateBlockStmt(info, node.Body))
+	if node.Post != nil {
+		writeBlock(&buf, i(translateStmt(info, node.Post)))
+	}
+	buf.WriteString("end\n")
 	return buf.String()
 }
 
@@ -457,30 +690,19 @@ func translateStmt(info *types.Info, stmt ast.Stmt) string {
 	var buf strings.Builder
 	switch s := stmt.(type) {
 	case *ast.AssignStmt:
-		for i, lhs := range s.Lhs {
-			if i > 0 {
-				buf.WriteString(", ")
-			}
-			buf.WriteString(translateExpr(info, lhs))
-		}
-		buf.WriteString(" ")
-		buf.WriteString(s.Tok.String())
-		buf.WriteString(" ")
-		for i, rhs := range s.Rhs {
-			if i > 0 {
-				buf.WriteString(", ")
-			}
-			buf.WriteString(translateExpr(info, rhs))
-		}
+		buf.WriteString(translateAssignStmt(info, s))
 
 	case *ast.BlockStmt:
 		buf.WriteString(translateBlockStmt(info, s))
 
 	case *ast.DeclStmt:
+		if isClosureBinding(info, s) {
+			return ""
+		}
 		buf.WriteString(translateDecl(info, s.Decl))
 
 	case *ast.ExprStmt:
-		buf.WriteString(translateExpr(info, s.X))
+		buf.WriteString(translateExprStmt(info, s))
 
 	case *ast.IncDecStmt:
 		buf.WriteString(translateIncDecStmt(info, s))
@@ -497,6 +719,24 @@ func translateStmt(info *types.Info, stmt ast.Stmt) string {
 	case *ast.SwitchStmt:
 		buf.WriteString(translateSwitchStmt(info, s))
 
+	case *ast.RangeStmt:
+		buf.WriteString(translateRangeStmt(info, s))
+
+	case *ast.GoStmt:
+		buf.WriteString(translateGoStmt(info, s))
+
+	case *ast.SendStmt:
+		buf.WriteString(translateSendStmt(info, s))
+
+	case *ast.SelectStmt:
+		buf.WriteString(translateSelectStmt(info, s))
+
+	case *ast.DeferStmt:
+		if erased, ok := translateSyncCall(info, s.Call); ok {
+			return erased
+		}
+		return fmt.Sprintf("/* unsupported statement type: %T */", s)
+
 	// Add cases for other statement types (e.g., *ast.BranchStmt,
 	// *ast.GoStmt, *ast.DeferStmt, etc.) as needed
 
@@ -511,6 +751,18 @@ func translateDecl(info *types.Info, decl ast.Decl) string {
 	var buf strings.Builder
 	switch d := decl.(type) {
 	case *ast.GenDecl: // General declaration (var, const, type, import)
+		if d.Tok == token.VAR || d.Tok == token.CONST {
+			var lines []string
+			for _, spec := range d.Specs {
+				if line := translateValueSpec(info, spec.(*ast.ValueSpec)); line != "" {
+					lines = append(lines, line)
+				}
+			}
+			return strings.Join(lines, "\n")
+		}
+		if d.Tok == token.TYPE {
+			return "" // see translateGenDecl
+		}
 		buf.WriteString(d.Tok.String()) // var, const, type, or import
 		buf.WriteString(" ")
 		for i, spec := range d.Specs {
@@ -538,99 +790,139 @@ func translateDecl(info *types.Info, decl ast.Decl) string {
 	return buf.String()
 }
 
+// translateValueSpec declares the variables or constants of a var or const
+// spec. Constants are declared with their value, which expands iota and
+// implicit repetition.
 func translateValueSpec(info *types.Info, node *ast.ValueSpec) string {
-	var buf strings.Builder
-	// Names of the values being declared (e.g., "x", "y", "z")
+	if decl, ok := translateSyncDecl(info, node.Names); ok {
+		return decl
+	}
+	var lines []string
 	for i, name := range node.Names {
-		if i > 0 {
-			buf.WriteString(", ")
+		obj := info.Defs[name]
+		switch c, isConst := obj.(*types.Const); {
+		case name.Name == "_":
+		case isConst:
+			lines = append(lines, declareValue(info, name, typedConstLiteral(name.Pos(), c.Type(), c.Val())))
+		case len(node.Values) == 0:
+			lines = append(lines, declareZero(info, name))
+		case len(node.Values) == len(node.Names):
+			lines = append(lines, declareValue(info, name, translateValue(info, node.Values[i], obj.Type())))
 		}
-		buf.WriteString(translateIdent(info, name))
 	}
-	// Optional type for the values
-	if node.Type != nil {
-		buf.WriteString(" ")
-		buf.WriteString(translateExpr(info, node.Type))
+	if _, isConst := info.Defs[node.Names[0]].(*types.Const); !isConst && len(node.Values) == 1 && len(node.Names) > 1 {
+		return declareTuple(info, node.Names, node.Values[0])
 	}
-	// Optional initial values
-	if node.Values != nil {
-		buf.WriteString(" = ")
-		for i, value := range node.Values {
-			if i > 0 {
-				buf.WriteString(", ")
-			}
-			buf.WriteString(translateExpr(info, value))
-		}
+	return strings.Join(lines, "\n")
+}
+
+// declareValue declares a variable initialized to value. The Evy type is
+// spelled out when Evy would infer a different one from the value.
+func declareValue(info *types.Info, id *ast.Ident, value string) string {
+	obj := info.ObjectOf(id)
+	if _, boxed := boxes[obj]; boxed || (frame != nil && frame.names[obj] != "") {
+		return declareVar(info, id, value)
+	}
+	t := evyType(obj.Type())
+	switch {
+	case value == "[]" || value == "{}":
+		return translateIdent(info, id) + ":" + t.String()
+	case types.IsInterface(obj.Type()):
+		return translateIdent(info, id) + ":any\n" + translateIdent(info, id) + " = " + value
+	}
+	return declareVar(info, id, value)
+}
+
+// declareZero declares a variable initialized to the zero value of its type.
+func declareZero(info *types.Info, id *ast.Ident) string {
+	obj := info.ObjectOf(id)
+	zero := zeroValue(obj.Type())
+	if _, boxed := boxes[obj]; boxed || (frame != nil && frame.names[obj] != "") {
+		return declareVar(info, id, zero)
+	}
+	if zero != "[]" && zero != "{}" && strings.ContainsAny(zero, "[{(") {
+		// Arrays and structs have elements and fields to zero.
+		return declareValue(info, id, zero)
 	}
+	return translateIdent(info, id) + ":" + evyType(obj.Type()).String()
+}
 
-	return buf.String()
+// declareTuple declares the variables initialized by a call returning
+// several results, which Evy receives as an array.
+func declareTuple(info *types.Info, ids []*ast.Ident, call ast.Expr) string {
+	tmp := newTemp("r")
+	lines := []string{tmp + " := " + translateExpr(info, call)}
+	for i, id := range ids {
+		if id.Name == "_" {
+			continue
+		}
+		t := evyType(info.ObjectOf(id).Type())
+		lines = append(lines, declareVar(info, id, fmt.Sprintf("%s[%d].(%s)", tmp, i, t)))
+	}
+	return strings.Join(lines, "\n")
 }
 
+// translateSwitchStmt translates a switch statement to an if chain, with
+// the default clause last.
 func translateSwitchStmt(info *types.Info, node *ast.SwitchStmt) string {
 	var buf strings.Builder
-
-	buf.WriteString("switch ")
-
-	// Optional initialization statement
 	if node.Init != nil {
 		buf.WriteString(translateStmt(info, node.Init))
-		buf.WriteString("; ")
+		buf.WriteString("\n")
 	}
-
-	// Check if it's a type switch
-	if node.Tag == nil {
-		// No tag means it's a type switch
-		buf.WriteString("true") // This is how type switches are internally represented in Go
-	} else {
-		// Expression-based switch
-		buf.WriteString(translateExpr(info, node.Tag))
+	tag := ""
+	if node.Tag != nil {
+		tag = translateOperand(info, node.Tag)
+		if !isStable(info, node.Tag, nil) {
+			tmp := newTemp("tag")
+			buf.WriteString(tmp + " := " + tag + "\n")
+			tag = tmp
+		}
 	}
-
-	buf.WriteString(" {\n")
-
+	var def *ast.CaseClause
+	keyword := "if "
 	for _, stmt := range node.Body.List {
-		caseClause, ok := stmt.(*ast.CaseClause)
-		if ok {
-			// Case expressions/types
-			buf.WriteString("case ")
-			for i, expr := range caseClause.List {
-				if i > 0 {
-					buf.WriteString(", ")
-				}
-				if node.Tag == nil {
-					// Type switch: expressions are type assertions
-					buf.WriteString(translateExpr(info, expr))
-				} else {
-					// Expression switch: expressions are values to compare
-					buf.WriteString(translateExpr(info, expr))
-				}
-			}
-			buf.WriteString(":\n")
-
-			// Case body
-			for _, caseStmt := range caseClause.Body {
-				buf.WriteString(translateStmt(info, caseStmt))
-				buf.WriteString("\n")
+		clause := stmt.(*ast.CaseClause)
+		if clause.List == nil {
+			def = clause
+			continue
+		}
+		var conds []string
+		for _, expr := range clause.List {
+			if node.Tag == nil {
+				conds = append(conds, translateExpr(info, expr))
+			} else {
+				conds = append(conds, tag+" == "+translateOperand(info, expr))
 			}
 		}
+		buf.WriteString(keyword)
+		buf.WriteString(strings.Join(conds, " or "))
+		buf.WriteString("\n")
+		writeBlock(&buf, translateCaseBody(info, clause))
+		keyword = "else if "
 	}
-
-	buf.WriteString("}\n") // Close the switch statement
-
+	if def != nil {
+		if keyword == "if " {
+			buf.WriteString("if true\n")
+		} else {
+			buf.WriteString("else\n")
+		}
+		writeBlock(&buf, translateCaseBody(info, def))
+	}
+	if keyword == "if " && def == nil {
+		return buf.String()
+	}
+	buf.WriteString("end\n")
 	return buf.String()
 }
 
-func translateFuncLit(info *types.Info, node *ast.FuncLit) string {
-	var buf strings.Builder
-
-	// Translate function type (parameters and results)
-	buf.WriteString(translateFuncType(info, node.Type))
-
-	// Translate the function body
-	buf.WriteString(" ")
-	buf.WriteString(translateBlockStmt(info, node.Body))
-
-	return buf.String()
+func translateCaseBody(info *types.Info, clause *ast.CaseClause) string {
+	if n := len(clause.Body); n > 0 {
+		if branch, ok := clause.Body[n-1].(*ast.BranchStmt); ok && branch.Tok == token.FALLTHROUGH {
+			diagnose(branch.Pos(), "fallthrough is not supported outside goroutines")
+		}
+	}
+	return translateBlockStmt(info, &ast.BlockStmt{List: clause.Body})
 }
 
 func translateFuncType(info *types.Info, node *ast.FuncType) string {
@@ -652,25 +944,34 @@ func translateFuncType(info *types.Info, node *ast.FuncType) string {
 	return buf.String()
 }
 
+// translateIfStmt translates an if statement and its else chain. An init
+// statement runs ahead of the if, in the enclosing scope.
 func translateIfStmt(info *types.Info, node *ast.IfStmt) string {
 	var buf strings.Builder
-	buf.WriteString("if ")
 	if node.Init != nil {
 		buf.WriteString(translateStmt(info, node.Init))
+		buf.WriteString("\n")
 	}
+	buf.WriteString("if ")
 	buf.WriteString(translateExpr(info, node.Cond))
 	buf.WriteString("\n")
-	buf.WriteString(translateBlockStmt(info, node.Body))
-	buf.WriteString("\n")
-	if node.Else != nil {
-		buf.WriteString(" else ")
-		if elseIf, ok := node.Else.(*ast.IfStmt); ok {
-			buf.WriteString(translateIfStmt(info, elseIf)) // Recursive call
-		} else {
-			buf.WriteString("")
-			buf.WriteString(translateStmt(info, node.Else))
+	writeBlock(&buf, translateBlockStmt(info, node.Body))
+	for els := node.Else; els != nil; {
+		if elseIf, ok := els.(*ast.IfStmt); ok && elseIf.Init == nil {
+			buf.WriteString("else if ")
+			buf.WriteString(translateExpr(info, elseIf.Cond))
 			buf.WriteString("\n")
+			writeBlock(&buf, translateBlockStmt(info, elseIf.Body))
+			els = elseIf.Else
+			continue
+		}
+		buf.WriteString("else\n")
+		if block, ok := els.(*ast.BlockStmt); ok {
+			writeBlock(&buf, translateBlockStmt(info, block))
+		} else {
+			writeBlock(&buf, i(translateStmt(info, els)))
 		}
+		break
 	}
 	buf.WriteString("end\n")
 	return buf.String()
@@ -693,14 +994,17 @@ func translateImportSpec(info *types.Info, node *ast.ImportSpec) string {
 }
 
 func translateIncDecStmt(info *types.Info, node *ast.IncDecStmt) string {
-	v := translateExpr(info, node.X)
-	if node.Tok == token.INC {
-		return fmt.Sprintf("%v = %v + 1", v, v)
+	op := token.ADD
+	if node.Tok == token.DEC {
+		op = token.SUB
 	}
-	return fmt.Sprintf("%v = %v - 1", v, v)
+	return translateCompound(info, node.Pos(), op, node.X, nil)
 }
 
 func translateIndexExpr(info *types.Info, node *ast.IndexExpr) string {
+	if m, ok := info.TypeOf(node.X).Underlying().(*types.Map); ok {
+		return mapRead(m, translateOperand(info, node.X), mapKey(info, m, node.Index))
+	}
 	var buf strings.Builder
 
 	// The expression being indexed (e.g., an array or slice)
@@ -795,52 +1099,168 @@ func translateParenExpr(info *types.Info, node *ast.ParenExpr) string {
 }
 
 func translateRangeStmt(info *types.Info, node *ast.RangeStmt) string {
+	if s, ok := translateTimerRange(info, node); ok {
+		return s
+	}
+	if _, ok := info.TypeOf(node.X).Underlying().(*types.Chan); ok {
+		return translateChanRange(info, node)
+	}
 	var buf strings.Builder
-	buf.WriteString("for ")
-
-	// Optional key/value assignments
-	if node.Key != nil {
-		if node.Tok == token.DEFINE { // Using := for short variable declaration
-			buf.WriteString(translateExpr(info, node.Key))
-			if node.Value != nil {
-				buf.WriteString(", ")
-				buf.WriteString(translateExpr(info, node.Value))
+	var key, value ast.Expr
+	if node.Key != nil && !isBlank(node.Key) {
+		key = node.Key
+	}
+	if node.Value != nil && !isBlank(node.Value) {
+		value = node.Value
+	}
+	x := translateOperand(info, node.X)
+	if value != nil && !isStable(info, node.X, nil) {
+		tmp := newTemp("x")
+		buf.WriteString(tmp + " := " + x + "\n")
+		x = tmp
+	}
+	// loopVar returns the Evy loop variable for a Go one. Assigning to
+	// existing variables goes through a fresh loop variable.
+	var binds []string
+	loopVar := func(expr ast.Expr) string {
+		if node.Tok == token.DEFINE {
+			return translateIdent(info, expr.(*ast.Ident))
+		}
+		tmp := newTemp("k")
+		binds = append(binds, translateLhs(info, expr)+" = "+tmp)
+		return tmp
+	}
+	bind := func(expr ast.Expr, v string) {
+		if node.Tok == token.DEFINE {
+			binds = append(binds, translateIdent(info, expr.(*ast.Ident))+" := "+v)
+		} else {
+			binds = append(binds, translateLhs(info, expr)+" = "+v)
+		}
+	}
+	header := "for "
+	switch t := info.TypeOf(node.X).Underlying().(type) {
+	case *types.Map:
+		switch {
+		case key != nil && isString(t.Key()):
+			k := loopVar(key)
+			header += k + " := range " + x
+			if value != nil {
+				bind(value, x+"["+k+"]")
 			}
-		} else { // Using = for assignment
-			buf.WriteString(translateExpr(info, node.Key))
-			if node.Value != nil {
-				buf.WriteString(" = ")
-				buf.WriteString(translateExpr(info, node.Value))
+		case key != nil:
+			k := newTemp("k")
+			header += k + " := range " + x
+			bind(key, keyValue(t, k))
+			if value != nil {
+				bind(value, x+"["+k+"]")
 			}
+		case value != nil:
+			k := newTemp("k")
+			header += k + " := range " + x
+			bind(value, x+"["+k+"]")
+		default:
+			header += "range " + x
+		}
+	case *types.Basic:
+		if t.Info()&types.IsString == 0 {
+			if key != nil {
+				header += loopVar(key) + " := "
+			}
+			header += "range " + x
+			break
+		}
+		if value != nil {
+			diagnose(node.Pos(), "ranging over a string yields one-character strings, not runes")
+		}
+		switch {
+		case key != nil:
+			k := loopVar(key)
+			header += k + " := range (len " + x + ")"
+			if value != nil {
+				bind(value, x+"["+k+"]")
+			}
+		case value != nil:
+			header += loopVar(value) + " := range " + x
+		default:
+			header += "range " + x
+		}
+	default:
+		switch {
+		case key != nil:
+			k := loopVar(key)
+			header += k + " := range (len " + x + ")"
+			if value != nil {
+				bind(value, x+"["+k+"]")
+			}
+		case value != nil:
+			header += loopVar(value) + " := range " + x
+		default:
+			header += "range " + x
 		}
-		buf.WriteString(" := ")
 	}
-
-	// The expression being ranged over
-	buf.WriteString("range ")
-	buf.WriteString(translateExpr(info, node.X))
-
-	// Body of the loop
-	buf.WriteString(" ")
-	buf.WriteString(translateBlockStmt(info, node.Body))
-
+	buf.WriteString(header)
+	buf.WriteString("\n")
+	if len(binds) > 0 {
+		buf.WriteString(i(strings.Join(binds, "\n")))
+		buf.WriteString("\n")
+	}
+	if node.Tok == token.DEFINE {
+		if prefix := boxPrefix(info, key, value); prefix != "" {
+			buf.WriteString(i(prefix))
+			buf.WriteString("\n")
+		}
+	}
+	writeBlock(&buf, translateBlockStmt(info, node.Body))
+	buf.WriteString("end\n")
 	return buf.String()
 }
 func translateReturnStmt(info *types.Info, node *ast.ReturnStmt) string {
 	var buf strings.Builder
 	buf.WriteString("return")
-	if len(node.Results) > 0 { // Check if there are values to return
-		buf.WriteString(" ")
+	if len(node.Results) == 0 && len(funcResults) > 0 {
+		var results []string
+		for _, id := range funcResults {
+			if id.Name == "_" {
+				results = append(results, zeroValue(info.Defs[id].Type()))
+			} else {
+				results = append(results, translateIdent(info, id))
+			}
+		}
+		if len(results) > 1 {
+			return "return [" + strings.Join(results, " ") + "]"
+		}
+		return "return " + results[0]
+	}
+	if len(node.Results) > 1 { // Multiple results are returned as an array, see resultType
+		buf.WriteString(" [")
 		for i, result := range node.Results {
 			if i > 0 {
-				buf.WriteString(", ")
+				buf.WriteString(" ")
+			}
+			if isNil(info, result) {
+				buf.WriteString(zeroValue(resultTypes[i]))
+				continue
 			}
-			buf.WriteString(translateExpr(info, result))
+			buf.WriteString(translateOperand(info, result))
 		}
+		buf.WriteString("]")
+	} else if len(node.Results) > 0 { // Check if there are values to return
+		buf.WriteString(" ")
+		buf.WriteString(translateValue(info, node.Results[0], resultTypes[0]))
 	}
 	return buf.String()
 }
 
+// translateFieldRead translates reading a struct field. Structs are maps
+// of any, so the value is asserted to the Evy type of the field.
+func translateFieldRead(info *types.Info, node *ast.SelectorExpr) string {
+	s := translateSelectorExpr(info, node)
+	if t := evyType(info.TypeOf(node)); t != evy.ANY_TYPE {
+		return s + ".(" + t.String() + ")"
+	}
+	return s
+}
+
 func translateSelectorExpr(info *types.Info, node *ast.SelectorExpr) string {
 	var buf strings.Builder
 	buf.WriteString(translateExpr(info, node.X))
@@ -851,13 +1271,18 @@ func translateSelectorExpr(info *types.Info, node *ast.SelectorExpr) string {
 
 func translateSendStmt(info *types.Info, node *ast.SendStmt) string {
 	var buf strings.Builder
+	buf.WriteString(useHelper("__send_now"))
+	buf.WriteString(" ")
 	buf.WriteString(translateExpr(info, node.Chan))
-	buf.WriteString(" <- ")
+	buf.WriteString(" ")
 	buf.WriteString(translateExpr(info, node.Value))
 	return buf.String()
 }
 
 func translateSliceExpr(info *types.Info, node *ast.SliceExpr) string {
+	if tmp, ok := sliceTemps[node]; ok {
+		return tmp
+	}
 	var buf strings.Builder
 	buf.WriteString(translateExpr(info, node.X))
 	buf.WriteString("[")
@@ -868,9 +1293,8 @@ func translateSliceExpr(info *types.Info, node *ast.SliceExpr) string {
 	if node.High != nil {
 		buf.WriteString(translateExpr(info, node.High))
 	}
-	if node.Max != nil {
-		buf.WriteString(":")
-		buf.WriteString(translateExpr(info, node.Max))
+	if node.Slice3 {
+		diagnose(node.Pos(), "three-index slices are not supported: Evy arrays have no capacity to limit")
 	}
 	buf.WriteString("]")
 	return buf.String()
@@ -893,11 +1317,9 @@ func translateStructType(info *types.Info, node *ast.StructType) string {
 
 func translateTypeAssertExpr(info *types.Info, node *ast.TypeAssertExpr) string {
 	var buf strings.Builder
-	buf.WriteString(translateExpr(info, node.X))
+	buf.WriteString(translateOperand(info, node.X))
 	buf.WriteString(".(")
-	if node.Type != nil {
-		buf.WriteString(translateExpr(info, node.Type))
-	}
+	buf.WriteString(evyType(info.TypeOf(node)).String())
 	buf.WriteString(")")
 	return buf.String()
 }
@@ -912,21 +1334,40 @@ func translateTypeSpec(info *types.Info, node *ast.TypeSpec) string {
 }
 
 func translateUnaryExpr(info *types.Info, node *ast.UnaryExpr) string {
+	if node.Op == token.ARROW {
+		if s, ok := translateTimerRecv(info, node); ok {
+			return s
+		}
+		return translateRecv(info, node)
+	}
+	if node.Op == token.AND {
+		return translateExpr(info, node.X) // pointers are erased, see evyType
+	}
+	if node.Op == token.XOR {
+		return "(" + translateComplement(info.TypeOf(node), translateOperand(info, node.X)) + ")"
+	}
 	str := "("
 	str += node.Op.String()
 	str += "("
 	str += translateExpr(info, node.X)
 	str += ")"
 	str += ")"
+	if wrapped := wrapValue(info.TypeOf(node), str); node.Op == token.SUB && wrapped != str {
+		return "(" + wrapped + ")"
+	}
 	return str
 }
 
 // ... other parts of your translation code ...
 
 func translateBinaryExpr(info *types.Info, node *ast.BinaryExpr) string {
-	x := translateNode(info, node.X)
-	y := translateNode(info, node.Y)
-	return x + " " + translateOperator(node.Op) + " " + y
+	if s, ok := translateNilComparison(info, node); ok {
+		return s
+	}
+	x := translateOperand(info, node.X)
+	y := translateOperand(info, node.Y)
+	diagnoseInexact(info, node.Pos(), node.Op, info.TypeOf(node), node.X, node.Y)
+	return translateArith(node.Pos(), node.Op, info.TypeOf(node), x, y)
 }
 
 func translateArrayType(info *types.Info, node *ast.ArrayType) string {
@@ -937,13 +1378,9 @@ func translateGenDecl(info *types.Info, node *ast.GenDecl) string {
 	// Handle different types of declarations within a GenDecl
 	switch node.Tok {
 	case token.VAR, token.CONST:
-		// Handle variable declarations
-		varSpecs := make([]string, len(node.Specs))
-		for i, spec := range node.Specs {
-			varSpecs[i] = translateNode(info, spec)
-		}
-		return strings.Join(varSpecs, "\n")
-	case token.IMPORT:
+		return "" // see translateGlobals
+	case token.IMPORT, token.TYPE:
+		// Evy has no type declarations: structs are maps, see evyType.
 		return ""
 	default:
 		panic("Unsupported GenDecl token") // Handle unsupported declaration types
@@ -951,98 +1388,375 @@ func translateGenDecl(info *types.Info, node *ast.GenDecl) string {
 }
 
 func translateFile(info *types.Info, file *ast.File) string {
+	resetHelpers()
+	renameGlobals(info, file)
+	analyzeSync(info, file)
+	liftClosures(info, file)
+	collectFuncValues(info, file)
+	analyzeScopes(info, file)
+	collectFlags(info, file)
+	analyzeSlices(info, file)
+	collectTasks(info, file)
 	var statements []string
+	if globals := translateGlobals(info, file); globals != "" {
+		statements = append(statements, globals)
+	}
 	for _, decl := range file.Decls {
 		stmt := translateNode(info, decl)
 		if stmt != "" {
 			statements = append(statements, stmt)
 		}
 	}
+	for _, c := range closureOrder {
+		statements = append(statements, translateClosure(info, c))
+	}
+	for _, node := range libraryValues {
+		statements = append(statements, translateWrapper(info, node))
+	}
+	if apply := translateApplyFuncs(); apply != "" {
+		statements = append(statements, apply)
+	}
+	if dispatch := translateDispatch(); dispatch != "" {
+		statements = append([]string{dispatch}, statements...)
+	}
+	if helpers := helperSource(); helpers != "" {
+		statements = append([]string{helpers}, statements...)
+	}
 
 	return strings.Join(statements, "\n")
 }
 
 func translateFuncDecl(info *types.Info, funcDecl *ast.FuncDecl) string {
+	step := ""
+	if funcDecl.Recv == nil && taskFuncs[funcDecl.Name.Name] {
+		step = translateTaskDecl(info, funcDecl.Name.Name, nil, funcDecl.Type, funcDecl.Body)
+		if !plainFuncs[funcDecl.Name.Name] {
+			return step
+		}
+	}
 	var buf bytes.Buffer
 	// Function signature
 	name := translateIdent(info, funcDecl.Name)
-	fmt.Fprintf(&buf, "func %s", name)
-
-	// Parameters
-	for i, field := range funcDecl.Type.Params.List {
-		for j, name := range field.Names {
-			if j > 0 {
-				buf.WriteString(" ")
-			}
-			fmt.Fprintf(&buf, "%s ", translateIdent(info, name))
-		}
-		if len(field.Names) > 0 {
-			buf.WriteString(" ")
-		}
-		buf.WriteString(translateExpr(info, field.Type))
-		if i < len(funcDecl.Type.Params.List)-1 {
-			buf.WriteString(" ")
-		}
-	}
-	buf.WriteString(" ")
-	//Results
-	if funcDecl.Type.Results != nil {
-		buf.WriteString("")
-		for i, field := range funcDecl.Type.Results.List {
-			if i > 0 {
-				buf.WriteString(" ")
-			}
-			buf.WriteString(translateExpr(info, field.Type))
+	sig := info.Defs[funcDecl.Name].Type().(*types.Signature)
+	params := paramDecls(sig.Params(), sig.Variadic())
+	if recv := sig.Recv(); recv != nil {
+		name = funcName(info.Defs[funcDecl.Name].(*types.Func))
+		recvName := localName(recv)
+		if recvName == "" {
+			recvName = "_"
 		}
-		buf.WriteString(" ")
+		params = append([]string{recvName + ":" + evyType(recv.Type()).String()}, params...)
 	}
-	buf.WriteString("\n")
+	buf.WriteString(translateSignature(name, params, resultType(sig.Results())))
 	// Function body
 	if funcDecl.Body != nil {
-		for _, decl := range funcDecl.Body.List {
-			buf.WriteString(i(translateStmt(info, decl)))
-			buf.WriteString("\n")
-		}
-		buf.WriteString("end\n")
+		buf.WriteString(translateFuncBody(info, funcDecl.Type, funcDecl.Body))
 	} else {
 		buf.WriteString("\n\t//Empty Function \n")
 	}
 	if name == "main" {
 		buf.WriteString("main\n")
 	}
+	if step != "" {
+		return buf.String() + "\n" + step
+	}
+	return buf.String()
+}
+
+// translateSignature returns the header of an Evy function declaration,
+// such as "func area:num w:num h:num".
+func translateSignature(name string, params []string, result string) string {
+	header := "func " + name
+	if result != "" {
+		header += ":" + result
+	}
+	for _, param := range params {
+		header += " " + param
+	}
+	return header + "\n"
+}
+
+// paramDecls declares Go parameters as Evy ones. Sync primitives are
+// dropped; see translateSyncCall.
+func paramDecls(params *types.Tuple, variadic bool) []string {
+	var decls []string
+	for i := 0; i < params.Len(); i++ {
+		param := params.At(i)
+		if isErasedSync(param.Type()) {
+			continue
+		}
+		name := localName(param)
+		if name == "" {
+			name = "_"
+		}
+		if variadic && i == params.Len()-1 {
+			decls = append(decls, name+":"+evyType(param.Type().(*types.Slice).Elem()).String()+"...")
+			continue
+		}
+		decls = append(decls, name+":"+evyType(param.Type()).String())
+	}
+	return decls
+}
+
+// resultType returns the Evy result type of a function. Evy functions
+// return a single value, so multiple results are returned as an array.
+func resultType(results *types.Tuple) string {
+	switch results.Len() {
+	case 0:
+		return ""
+	case 1:
+		return evyType(results.At(0).Type()).String()
+	}
+	return "[]any"
+}
+
+// funcResults are the named results of the function being translated,
+// which a bare return returns, and resultTypes the types of its results.
+var (
+	funcResults []*ast.Ident
+	resultTypes []types.Type
+)
+
+// translateFuncBody translates a function body and its closing end. Named
+// results are declared as zero-valued locals.
+func translateFuncBody(info *types.Info, typ *ast.FuncType, body *ast.BlockStmt) string {
+	var buf strings.Builder
+	var ids []ast.Expr
+	for _, field := range typ.Params.List {
+		for _, id := range field.Names {
+			ids = append(ids, id)
+		}
+	}
+	if prefix := boxPrefix(info, ids...); prefix != "" {
+		buf.WriteString(i(prefix))
+		buf.WriteString("\n")
+	}
+	outer, outerTypes := funcResults, resultTypes
+	defer func() { funcResults, resultTypes = outer, outerTypes }()
+	for _, decl := range declareResults(info, typ) {
+		buf.WriteString(i(decl))
+		buf.WriteString("\n")
+	}
+	for _, stmt := range body.List {
+		evyStmt := translateStmt(info, stmt)
+		if evyStmt == "" {
+			continue
+		}
+		buf.WriteString(i(evyStmt))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("end\n")
 	return buf.String()
 }
 
+// declareResults sets funcResults and resultTypes for a function of type
+// typ and returns the declarations of its named results.
+func declareResults(info *types.Info, typ *ast.FuncType) []string {
+	funcResults, resultTypes = nil, nil
+	if typ.Results == nil {
+		return nil
+	}
+	var decls []string
+	for _, field := range typ.Results.List {
+		for range max(len(field.Names), 1) {
+			resultTypes = append(resultTypes, info.TypeOf(field.Type))
+		}
+		for _, id := range field.Names {
+			funcResults = append(funcResults, id)
+			if id.Name != "_" {
+				decls = append(decls, declareZero(info, id))
+			}
+		}
+	}
+	return decls
+}
+
 func translateIdent(info *types.Info, ident *ast.Ident) string {
-	str := ident.String()
-	switch str {
-	case "Println":
-		return "print"
-	case "Printf":
-		return "printf"
-	default:
-		return str
+	if obj := info.Uses[ident]; obj != nil {
+		if ref, ok := varRef(obj); ok {
+			return ref
+		}
+	}
+	if name, ok := renames[info.ObjectOf(ident)]; ok {
+		return name
 	}
+	return ident.String()
 }
 
 func translateBlockStmt(info *types.Info, blockStmt *ast.BlockStmt) string {
 	var statements []string
 	for _, goStmt := range blockStmt.List {
-		evyStmt := translateNode(info, goStmt) // Recursively translate each statement in the block
+		evyStmt := translateStmt(info, goStmt) // Recursively translate each statement in the block
 		if evyStmt != "" {                     // Ignore unsupported statements (if any)
 			statements = append(statements, evyStmt)
 		}
 	}
+	if len(statements) == 0 {
+		return ""
+	}
 	return i(strings.Join(statements, "\n"))
 }
 
-func translateAssignStmt(into *types.Info, assignStmt *ast.AssignStmt) string {
-	var lhs, rhs string
-	for i, lhsExpr := range assignStmt.Lhs {
-		lhs += translateNode(into, lhsExpr)
-		rhs += translateNode(into, assignStmt.Rhs[i])
+// writeBlock writes the translation of a block on its own lines, if it has
+// any statements.
+func writeBlock(buf *strings.Builder, block string) {
+	if block != "" {
+		buf.WriteString(block)
+		buf.WriteString("\n")
+	}
+}
+
+func translateAssignStmt(info *types.Info, assignStmt *ast.AssignStmt) string {
+	if isClosureBinding(info, assignStmt) {
+		return ""
+	}
+	if assignStmt.Tok == token.DEFINE {
+		var names []*ast.Ident
+		for _, lhs := range assignStmt.Lhs {
+			names = append(names, lhs.(*ast.Ident))
+		}
+		if decl, ok := translateSyncDecl(info, names); ok {
+			return decl
+		}
+	}
+	return translateAssign(info, assignStmt)
+}
+
+// translateLhs translates an assignment target. Unlike translateExpr it
+// yields a storage location, so task frame variables are not type asserted.
+func translateLhs(info *types.Info, expr ast.Expr) string {
+	if ix, ok := expr.(*ast.IndexExpr); ok {
+		// An element is written, not read, so a map key need not exist.
+		if m, ok := info.TypeOf(ix.X).Underlying().(*types.Map); ok {
+			return translateExpr(info, ix.X) + "[" + mapKey(info, m, ix.Index) + "]"
+		}
+		return translateExpr(info, ix.X) + "[" + translateExpr(info, ix.Index) + "]"
+	}
+	if sel, ok := expr.(*ast.SelectorExpr); ok && info.Selections[sel] != nil {
+		return translateSelectorExpr(info, sel) // a field is written, not read
+	}
+	if id, ok := expr.(*ast.Ident); ok {
+		obj := info.ObjectOf(id)
+		if ref, ok := varRef(obj); ok && boxes[obj] != "" {
+			return ref
+		}
+		if frame != nil && frame.names[obj] != "" {
+			return "__t." + frame.names[obj]
+		}
+	}
+	return translateExpr(info, expr)
+}
+
+// translateOperand translates an expression used as an argument or operand,
+// parenthesizing calls so they do not swallow the arguments that follow.
+func translateOperand(info *types.Info, expr ast.Expr) string {
+	s := translateExpr(info, expr)
+	if call, ok := ast.Unparen(expr).(*ast.CallExpr); ok && (strings.Contains(s, " ") || len(call.Args) == 0) && !strings.HasPrefix(s, "(") {
+		return "(" + s + ")"
+	}
+	if strings.HasPrefix(s, "-") {
+		return "(" + s + ")" // a folded negative constant
+	}
+	return s
+}
+
+// evyType maps a Go type to the Evy type used to represent its values.
+// Structs and channels become maps; pointers share their element's type.
+func evyType(t types.Type) *evy.Type {
+	if syncTypeName(t) == "Once" {
+		return evy.BOOL_TYPE // see translateSyncDecl
+	}
+	if isTimeNum(t) {
+		return evy.NUM_TYPE // see time.go
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsNumeric != 0:
+			return evy.NUM_TYPE
+		case u.Info()&types.IsString != 0:
+			return evy.STRING_TYPE
+		case u.Info()&types.IsBoolean != 0:
+			return evy.BOOL_TYPE
+		}
+	case *types.Slice:
+		return &evy.Type{Name: evy.ARRAY, Sub: evyType(u.Elem())}
+	case *types.Array:
+		return &evy.Type{Name: evy.ARRAY, Sub: evyType(u.Elem())}
+	case *types.Map:
+		return &evy.Type{Name: evy.MAP, Sub: evyType(u.Elem())}
+	case *types.Pointer:
+		return evyType(u.Elem())
+	case *types.Struct, *types.Chan:
+		return &evy.Type{Name: evy.MAP, Sub: evy.ANY_TYPE}
+	case *types.Signature:
+		return &evy.Type{Name: evy.ARRAY, Sub: evy.ANY_TYPE} // see funcValue
+	}
+	return evy.ANY_TYPE
+}
+
+// structValue returns the Evy map holding a struct's fields, except for
+// erased sync primitives, with the values value gives.
+func structValue(t *types.Struct, value func(field *types.Var) string) string {
+	var fields []string
+	for i := 0; i < t.NumFields(); i++ {
+		field := t.Field(i)
+		if field.Name() == "_" || isErasedSync(field.Type()) {
+			continue
+		}
+		fields = append(fields, localName(field)+": "+value(field))
+	}
+	return "{" + strings.Join(fields, " ") + "}"
+}
+
+// isLibraryType reports whether t is a named type declared by another
+// package than the file's.
+func isLibraryType(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg() != filePkg
+}
+
+// zeroArray returns the zero value of a Go array: its length of element
+// zero values, made by a helper when the array is long and they are not
+// shared by reference.
+func zeroArray(t *types.Array) string {
+	zero := zeroValue(t.Elem())
+	if t.Len() > 8 && !strings.ContainsAny(zero, "[{(") {
+		return "(" + evyCall(typedHelper("__make", evyType(t.Elem()).String(), makeSource(zero)), fmt.Sprint(t.Len())) + ")"
+	}
+	elems := make([]string, t.Len())
+	for i := range elems {
+		elems[i] = zero
+	}
+	return "[" + strings.Join(elems, " ") + "]"
+}
+
+// zeroValue returns the Evy literal for the zero value of a Go type.
+func zeroValue(t types.Type) string {
+	if _, isPtr := t.(*types.Pointer); !isPtr && isSyncType(t) && !isErasedSync(t) {
+		return "(" + useHelper("__wait_group") + ")" // see translateSyncDecl
+	}
+	et := evyType(t)
+	switch {
+	case et == evy.NUM_TYPE:
+		return "0"
+	case et == evy.STRING_TYPE:
+		return `""`
+	case et == evy.BOOL_TYPE:
+		return "false"
+	case et.Name == evy.ARRAY:
+		if a, ok := t.Underlying().(*types.Array); ok {
+			return zeroArray(a)
+		}
+		return "[]"
+	case et == evy.ANY_TYPE:
+		return "false" // the zero value of an Evy any
+	}
+	// The fields of a library struct are not the file's to zero.
+	if s, ok := t.Underlying().(*types.Struct); ok && !isLibraryType(t) {
+		return structValue(s, func(field *types.Var) string { return zeroValue(field.Type()) })
 	}
-	return lhs + " = " + rhs
+	return "{}"
 }
 
 func toEvyType(in string) *evy.Type {
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// An error is represented by its message, a string, and nil by the zero
+// value of the type it is used as: false for interfaces, as for an Evy
+// variable of type any, and empty arrays and maps for slices and maps. Go
+// leaves nil untyped, so it is translated where the target type is known.
+
+func isNil(info *types.Info, expr ast.Expr) bool {
+	id, ok := ast.Unparen(expr).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, ok = info.Uses[id].(*types.Nil)
+	return ok
+}
+
+// translateValue translates a value assigned, passed or returned as type t.
+func translateValue(info *types.Info, expr ast.Expr, t types.Type) string {
+	if t != nil && isNil(info, expr) {
+		return zeroValue(t)
+	}
+	return translateExpr(info, expr)
+}
+
+// paramType returns the type of the nth argument of a call.
+func paramType(sig *types.Signature, n int) types.Type {
+	params := sig.Params()
+	if sig.Variadic() && n >= params.Len()-1 {
+		return params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+	}
+	return params.At(n).Type()
+}
+
+// translateNilComparison translates comparing a value with nil. Nil and
+// empty slices and maps are not told apart.
+func translateNilComparison(info *types.Info, node *ast.BinaryExpr) (string, bool) {
+	x := node.X
+	switch {
+	case node.Op != token.EQL && node.Op != token.NEQ:
+		return "", false
+	case isNil(info, node.X):
+		x = node.Y
+	case !isNil(info, node.Y):
+		return "", false
+	}
+	op := node.Op.String()
+	switch t := info.TypeOf(x); t.Underlying().(type) {
+	case *types.Interface:
+		return translateOperand(info, x) + " " + op + " false", true
+	case *types.Slice, *types.Map:
+		return "(len " + translateOperand(info, x) + ") " + op + " 0", true
+	case *types.Signature:
+		// A nil function value is the empty array, see funcValue.
+		return "(len " + translateOperand(info, x) + ") " + op + " 0", true
+	default:
+		diagnose(node.Pos(), "comparing %s with nil is not supported", t)
+		return "", false
+	}
+}
+
+// translateErrorCall translates the Error method of the error interface.
+func translateErrorCall(info *types.Info, call *ast.CallExpr) (string, bool) {
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Error" || !types.Identical(info.TypeOf(sel.X), types.Universe.Lookup("error").Type()) {
+		return "", false
+	}
+	return translateOperand(info, sel.X) + ".(string)", true
+}
+
+// translateErrorsCall translates a call to a function of package errors.
+func translateErrorsCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	switch name {
+	case "New":
+		return translateExpr(info, call.Args[0]), true
+	case "Is":
+		return "(" + translateOperand(info, call.Args[0]) + " == " + translateOperand(info, call.Args[1]) + ")", true
+	}
+	diagnose(call.Pos(), "errors.%s is not supported", name)
+	return "", false
+}
+
+func init() {
+	stdlibCalls["errors"] = translateErrorsCall
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// Evy programs have no command line. A flag is a variable holding its
+// default, as flag.Int's pointer is erased, and os.Args holds only the
+// program name. With -prompt, flag.Parse asks for the value of each flag
+// defined before it, keeping the default on an empty answer, and os.Args
+// asks for the arguments the first time it is used.
+
+// promptFlags enables prompting for flags and arguments.
+var promptFlags bool
+
+// flagDef is a flag defined by the file: the variable holding it and the
+// call defining it.
+type flagDef struct {
+	target ast.Expr
+	call   *ast.CallExpr
+}
+
+// flagDefs holds the flags in the order they are defined.
+var flagDefs []flagDef
+
+// flagKinds maps the flag definitions to the kind of value they parse.
+var flagKinds = map[string]string{
+	"Int": "int", "Int64": "int", "Uint": "int", "Uint64": "int",
+	"Float64": "num", "String": "string", "Bool": "bool",
+}
+
+// flagFunc returns the name of the flag definition function call calls,
+// without its Var suffix, and whether it is one.
+func flagFunc(info *types.Info, call *ast.CallExpr) (name string, isVar bool) {
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return "", false
+	}
+	fn, ok := info.Uses[sel.Sel].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != "flag" {
+		return "", false
+	}
+	name = fn.Name()
+	isVar = strings.HasSuffix(name, "Var") && len(name) > 3
+	if isVar {
+		name = strings.TrimSuffix(name, "Var")
+	}
+	if flagKinds[name] == "" {
+		return "", false
+	}
+	return name, isVar
+}
+
+// collectFlags records the variables the file's flag definitions set.
+func collectFlags(info *types.Info, file *ast.File) {
+	flagDefs = nil
+	define := func(target, value ast.Expr) {
+		if call, ok := ast.Unparen(value).(*ast.CallExpr); ok {
+			if name, isVar := flagFunc(info, call); name != "" && !isVar {
+				flagDefs = append(flagDefs, flagDef{target, call})
+			}
+		}
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) == len(n.Rhs) {
+				for i := range n.Lhs {
+					define(n.Lhs[i], n.Rhs[i])
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) == len(n.Values) {
+				for i := range n.Names {
+					define(n.Names[i], n.Values[i])
+				}
+			}
+		case *ast.CallExpr:
+			if _, isVar := flagFunc(info, n); isVar {
+				if ptr, ok := ast.Unparen(n.Args[0]).(*ast.UnaryExpr); ok {
+					flagDefs = append(flagDefs, flagDef{ptr.X, n})
+				} else {
+					diagnose(n.Pos(), "flag variables are only supported given as &v")
+				}
+			}
+		}
+		return true
+	})
+}
+
+// translateFlagCall translates a call to a function of package flag.
+func translateFlagCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	if kind, isVar := flagFunc(info, call); kind != "" {
+		args := operands(info, call.Args)
+		if isVar {
+			ptr := ast.Unparen(call.Args[0]).(*ast.UnaryExpr)
+			return translateLhs(info, ptr.X) + " = " + args[2], true
+		}
+		return translateExpr(info, call.Args[1]), true
+	}
+	switch name {
+	case "Parse":
+		return translateFlagParse(info, call), true
+	case "Args":
+		return "(" + useHelper("__os_args") + ")[1:]", true
+	case "NArg":
+		return "((len (" + useHelper("__os_args") + ")) - 1)", true
+	case "Arg":
+		return evyCall(useHelper("__arg"), translateOperand(info, call.Args[0])), true
+	case "Parsed":
+		return "true", true
+	}
+	diagnose(call.Pos(), "flag.%s is not supported", name)
+	return "", false
+}
+
+// translateFlagParse prompts for the flags defined before the call to
+// flag.Parse, with -prompt.
+func translateFlagParse(info *types.Info, call *ast.CallExpr) string {
+	if !promptFlags {
+		return ""
+	}
+	var lines []string
+	for _, def := range flagDefs {
+		if def.call.Pos() > call.Pos() {
+			continue
+		}
+		name, isVar := flagFunc(info, def.call)
+		args := def.call.Args
+		if isVar {
+			args = args[1:]
+		}
+		var helper string
+		switch kind := flagKinds[name]; kind {
+		case "int", "num":
+			helper = fmt.Sprintf("%s %t", useHelper("__flag_num"), kind == "int")
+		default:
+			helper = useHelper("__flag_" + kind)
+		}
+		lines = append(lines, fmt.Sprintf("%s = %s %s %s %s", translateLhs(info, def.target), helper,
+			translateOperand(info, args[0]), translateOperand(info, args[2]), translateOperand(info, def.target)))
+	}
+	return joinLines(lines)
+}
+
+// promptInput registers the helpers that prompt for flags and arguments,
+// replacing those giving none.
+func promptInput() {
+	promptFlags = true
+	addHelpers(map[string]helper{
+		"__os_args": {deps: []string{"__args", "__fields"}, src: `
+func __os_args:[]string
+    if (len __args) == 0
+        printf "arguments: "
+        __args = ["main"] + (__fields (read))
+    end
+    return __args
+end`},
+		"__flag_string": {src: `
+func __flag_string:string name:string usage:string value:string
+    printf "-%v (%v) [%v]: " name usage value
+    s := read
+    if s == ""
+        return value
+    end
+    return s
+end`},
+		"__flag_num": {deps: []string{"__flag_invalid"}, src: `
+func __flag_num:num integer:bool name:string usage:string value:num
+    printf "-%v (%v) [%v]: " name usage value
+    s := read
+    if s == ""
+        return value
+    end
+    n := str2num s
+    if err or (integer and n != (floor n))
+        __flag_invalid s name
+    end
+    return n
+end`},
+		"__flag_bool": {deps: []string{"__flag_invalid"}, src: `
+func __flag_bool:bool name:string usage:string value:bool
+    printf "-%v (%v) [%v]: " name usage value
+    s := read
+    if s == ""
+        return value
+    end
+    b := str2bool s
+    if err
+        __flag_invalid s name
+    end
+    return b
+end`},
+		"__flag_invalid": {src: `
+func __flag_invalid s:string name:string
+    print (sprintf "invalid value %q for flag -%v: parse error" s name)
+    exit 2
+end`},
+	})
+}
+
+func init() {
+	stdlibCalls["flag"] = translateFlagCall
+	addHelpers(map[string]helper{
+		"__args": {src: "\n__args:[]string"},
+		"__os_args": {deps: []string{"__args"}, src: `
+func __os_args:[]string
+    if (len __args) == 0
+        __args = ["main"]
+    end
+    return __args
+end`},
+		"__arg": {deps: []string{"__os_args"}, src: `
+func __arg:string i:num
+    args := __os_args
+    if i < 0 or i + 1 >= (len args)
+        return ""
+    end
+    return args[i + 1]
+end`},
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// Evy's print separates its arguments by spaces and ends the line like
+// fmt.Println; every other fmt function is expressed with printf or
+// sprintf and a format built from Go's spacing rules.
+
+// translateFmtCall translates a call to a function of package fmt.
+// Writing to os.Stdout or os.Stderr prints, as Evy has a single output.
+func translateFmtCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	args := call.Args
+	if strings.Contains(strings.ToLower(name), "scan") {
+		diagnose(call.Pos(), "fmt.%s is only supported as a statement or assigned from", name)
+		return "", false
+	}
+	if strings.HasPrefix(name, "Fprint") {
+		if !isPkgVar(info, args[0], "os", "Stdout") && !isPkgVar(info, args[0], "os", "Stderr") {
+			diagnose(call.Pos(), "fmt.%s is only supported writing to os.Stdout or os.Stderr", name)
+			return "", false
+		}
+		args, name = args[1:], "P"+name[2:]
+	}
+	if call.Ellipsis.IsValid() {
+		diagnose(call.Pos(), "passing a slice to fmt.%s with ... is not supported", name)
+	}
+	switch name {
+	case "Println":
+		return evyCall("print", printOperands(info, args)...), true
+	case "Print":
+		return evyCall("printf", printArgs(info, args, false, "")...), true
+	case "Sprint":
+		return evyCall("sprintf", printArgs(info, args, false, "")...), true
+	case "Sprintln":
+		return evyCall("sprintf", printArgs(info, args, true, "\n")...), true
+	case "Printf":
+		return evyCall("printf", formatArgs(info, call.Pos(), args)...), true
+	case "Sprintf", "Errorf":
+		// Errors are represented by their message.
+		return evyCall("sprintf", formatArgs(info, call.Pos(), args)...), true
+	}
+	diagnose(call.Pos(), "fmt.%s is not supported", name)
+	return "", false
+}
+
+// printArgs returns a printf format for printing args as fmt.Print does,
+// or as fmt.Println does if spaced, followed by the translated arguments.
+// fmt.Print only separates operands when neither is a string.
+func printArgs(info *types.Info, args []ast.Expr, spaced bool, end string) []string {
+	var format strings.Builder
+	for n, arg := range args {
+		if n > 0 && (spaced || !isString(info.TypeOf(args[n-1])) && !isString(info.TypeOf(arg))) {
+			format.WriteString(" ")
+		}
+		format.WriteString("%v")
+	}
+	format.WriteString(end)
+	return append([]string{fmt.Sprintf("%q", format.String())}, printOperands(info, args)...)
+}
+
+// printOperands translates printed arguments, see printValue.
+func printOperands(info *types.Info, args []ast.Expr) []string {
+	ops := operands(info, args)
+	for n, arg := range args {
+		ops[n] = printValue(info, arg, ops[n])
+	}
+	return ops
+}
+
+// printValue returns the operand printing x, the translation of arg, as
+// fmt does: values of the file's types with an Error or String method as
+// the method formats them, durations as their String method does, and nil
+// errors and interfaces as <nil>. A nil interface is false, so a nil any
+// is only told apart from false when it is the literal nil.
+func printValue(info *types.Info, arg ast.Expr, x string) string {
+	t := info.TypeOf(arg)
+	if fn := stringMethod(t); fn != nil {
+		return "(" + evyCall(funcName(fn), x) + ")"
+	}
+	switch {
+	case isDuration(t):
+		return "(" + evyCall(useHelper("__duration"), x) + ")"
+	case isNil(info, arg):
+		return `"<nil>"`
+	case isFloat32(t):
+		return "(" + evyCall(useHelper("__f32_string"), x) + ")"
+	case types.IsInterface(t) && !t.Underlying().(*types.Interface).Empty():
+		return "(" + evyCall(useHelper("__nil_string"), x) + ")"
+	}
+	return x
+}
+
+// stringMethod returns the method of t declared in the file that fmt
+// formats its values with, Error or String, or nil. Values of interface
+// type are printed as they are.
+func stringMethod(t types.Type) *types.Func {
+	if types.IsInterface(t) {
+		return nil
+	}
+	mset := types.NewMethodSet(t)
+	for _, name := range []string{"Error", "String"} {
+		for i := 0; i < mset.Len(); i++ {
+			fn := mset.At(i).Obj().(*types.Func)
+			sig := fn.Type().(*types.Signature)
+			if fn.Name() == name && fileFuncs[fn] && sig.Params().Len() == 0 &&
+				sig.Results().Len() == 1 && isString(sig.Results().At(0).Type()) {
+				return fn
+			}
+		}
+	}
+	return nil
+}
+
+// translatePrintBuiltin translates the print and println builtins, which
+// Go writes to standard error.
+func translatePrintBuiltin(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	if name == "println" {
+		return evyCall("print", operands(info, call.Args)...), true
+	}
+	var format strings.Builder
+	for range call.Args {
+		format.WriteString("%v")
+	}
+	return evyCall("printf", append([]string{fmt.Sprintf("%q", format.String())}, operands(info, call.Args)...)...), true
+}
+
+func init() {
+	stdlibCalls["fmt"] = translateFmtCall
+	builtinCalls["print"] = translatePrintBuiltin
+	builtinCalls["println"] = translatePrintBuiltin
+	addHelpers(map[string]helper{
+		"__nil_string": {src: `
+func __nil_string:any x:any
+    if (typeof x) == "bool"
+        return "<nil>"
+    end
+    return x
+end`},
+	})
+}
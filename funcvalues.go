@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// Evy cannot store functions in variables, so function values are
+// defunctionalized. A function value is an array tagged with the name of
+// the Evy function it calls, followed by the values bound to it: the
+// captured variables of a closure or the receiver of a method value.
+// Calls through a function value go to a generated apply function for its
+// signature, which switches on the tag.
+
+// funcTarget is a function the file uses as a value.
+type funcTarget struct {
+	name  string
+	sig   *types.Signature
+	bound []string // Evy types of the bound values
+}
+
+var (
+	funcTargets []*funcTarget
+	// applySigs are the signatures called through function values, in the
+	// order their apply functions were first needed.
+	applySigs []*types.Signature
+	// fileFuncs are the functions and methods declared in the file.
+	fileFuncs map[*types.Func]bool
+	// libraryValues are the library functions the file uses as values,
+	// each lifted to a wrapper calling it, in order of first use.
+	libraryValues []*ast.SelectorExpr
+)
+
+// collectFuncValues finds every function, closure and method the file uses
+// as a value.
+func collectFuncValues(info *types.Info, file *ast.File) {
+	funcTargets = nil
+	applySigs = nil
+	libraryValues = nil
+	fileFuncs = map[*types.Func]bool{}
+	for _, decl := range file.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+			fileFuncs[info.Defs[funcDecl.Name].(*types.Func)] = true
+		}
+	}
+	seen := map[string]bool{}
+	add := func(name string, sig *types.Signature, bound ...string) {
+		if !seen[name] {
+			seen[name] = true
+			funcTargets = append(funcTargets, &funcTarget{name, sig, bound})
+		}
+	}
+	callees := map[ast.Expr]bool{}
+	selected := map[*ast.Ident]bool{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.CallExpr:
+			callees[ast.Unparen(n.Fun)] = true
+		case *ast.Ident:
+			if fn, ok := info.Uses[n].(*types.Func); ok && fileFuncs[fn] && !callees[n] && !selected[n] {
+				add(funcName(fn), fn.Type().(*types.Signature))
+			}
+		case *ast.FuncLit:
+			c := closures[n]
+			if c == nil {
+				diagnose(n.Pos(), "function literals outside functions and variable declarations are not supported")
+				return true
+			}
+			if !callees[n] && !isBoundClosure(c) {
+				var bound []string
+				for _, param := range freeParams(c) {
+					bound = append(bound, param[strings.Index(param, ":")+1:])
+				}
+				add(c.name, info.TypeOf(n).(*types.Signature), bound...)
+			}
+		case *ast.SelectorExpr:
+			selected[n.Sel] = true
+			if fn := libraryFunc(info, n); fn != nil && !callees[n] && !fn.Type().(*types.Signature).Variadic() {
+				if name := wrapperName(fn); !seen[name] {
+					libraryValues = append(libraryValues, n)
+					add(name, fn.Type().(*types.Signature))
+				}
+				return true
+			}
+			fn, sel := fileMethod(info, n)
+			if fn == nil || callees[n] {
+				return true
+			}
+			if sel.Kind() == types.MethodVal {
+				add(funcName(fn), sel.Type().(*types.Signature), evyType(sel.Recv()).String())
+			} else {
+				add(funcName(fn), sel.Type().(*types.Signature))
+			}
+		}
+		return true
+	})
+}
+
+// fileMethod returns the method a selector selects, if it is declared in
+// the file.
+func fileMethod(info *types.Info, node *ast.SelectorExpr) (*types.Func, *types.Selection) {
+	sel := info.Selections[node]
+	if sel == nil || sel.Kind() == types.FieldVal {
+		return nil, nil
+	}
+	if fn := sel.Obj().(*types.Func); fileFuncs[fn] {
+		return fn, sel
+	}
+	return nil, nil
+}
+
+// libraryFunc returns the package-level function of another package a
+// qualified identifier denotes, or nil. The split functions of package
+// bufio are left to translateBufioCall.
+func libraryFunc(info *types.Info, node *ast.SelectorExpr) *types.Func {
+	fn, ok := info.Uses[node.Sel].(*types.Func)
+	if !ok || info.Selections[node] != nil || fn.Pkg() == nil || fn.Pkg().Path() == "bufio" {
+		return nil
+	}
+	return fn
+}
+
+// wrapperName returns the name of the function wrapping a library
+// function used as a value, such as __strings_ToUpper.
+func wrapperName(fn *types.Func) string {
+	return "__" + fn.Pkg().Name() + "_" + fn.Name()
+}
+
+// translateLibraryFuncValue translates a library function used as a value
+// to a value calling its wrapper, see translateWrapper.
+func translateLibraryFuncValue(info *types.Info, node *ast.SelectorExpr) (string, bool) {
+	fn := libraryFunc(info, node)
+	if fn == nil {
+		return "", false
+	}
+	if fn.Type().(*types.Signature).Variadic() {
+		diagnose(node.Pos(), "variadic function %s.%s used as a value is not supported", fn.Pkg().Name(), fn.Name())
+	}
+	return funcValue(wrapperName(fn)), true
+}
+
+// translateWrapper emits the function wrapping a library function used as
+// a value, which calls it with its parameters as Evy would translate the
+// call. The parameters are recorded in info for the call's translation.
+func translateWrapper(info *types.Info, node *ast.SelectorExpr) string {
+	fn := libraryFunc(info, node)
+	sig := fn.Type().(*types.Signature)
+	call := &ast.CallExpr{Fun: node, Lparen: node.End(), Rparen: node.End()}
+	var params []string
+	for j := 0; j < sig.Params().Len(); j++ {
+		t := sig.Params().At(j).Type()
+		id := &ast.Ident{NamePos: node.Pos(), Name: fmt.Sprintf("__a%d", j+1)}
+		info.Uses[id] = types.NewParam(node.Pos(), fn.Pkg(), id.Name, t)
+		call.Args = append(call.Args, id)
+		params = append(params, id.Name+":"+evyType(t).String())
+	}
+	var result types.Type = sig.Results()
+	if sig.Results().Len() == 1 {
+		result = sig.Results().At(0).Type()
+	}
+	info.Types[call] = types.TypeAndValue{Type: result}
+	body := translateExpr(info, call)
+	if sig.Results().Len() > 0 {
+		body = "return " + body
+	}
+	return translateSignature(wrapperName(fn), params, resultType(sig.Results())) + i(body) + "\nend\n"
+}
+
+func isBoundClosure(c *closure) bool {
+	for _, bound := range closureVars {
+		if bound == c {
+			return true
+		}
+	}
+	return false
+}
+
+// funcName returns the Evy name of a function. Methods are lifted to
+// functions named after their receiver type, taking the receiver first.
+func funcName(fn *types.Func) string {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return localName(fn)
+	}
+	t := recv.Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return evyIdent(named.Obj().Name()) + "_" + evyIdent(fn.Name())
+	}
+	return fn.Name()
+}
+
+// funcValue returns the Evy value for a function with bound values.
+func funcValue(name string, bound ...string) string {
+	parts := append([]string{useHelper("__func"), fmt.Sprintf("%q", name)}, bound...)
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// translateFuncIdent translates a function declared in the file used as a
+// value rather than called.
+func translateFuncIdent(info *types.Info, id *ast.Ident) (string, bool) {
+	fn, ok := info.Uses[id].(*types.Func)
+	if !ok || !fileFuncs[fn] {
+		return "", false
+	}
+	return funcValue(funcName(fn)), true
+}
+
+// translateMethodSelector translates a method value or method expression.
+func translateMethodSelector(info *types.Info, node *ast.SelectorExpr) (string, bool) {
+	fn, sel := fileMethod(info, node)
+	if fn == nil {
+		return "", false
+	}
+	name := funcName(fn)
+	if sel.Kind() == types.MethodExpr {
+		return funcValue(name), true
+	}
+	return funcValue(name, translateOperand(info, node.X)), true
+}
+
+// translateMethodCall calls a method declared in the file as the function
+// it is lifted to.
+func translateMethodCall(info *types.Info, call *ast.CallExpr) (string, bool) {
+	node, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return "", false
+	}
+	fn, sel := fileMethod(info, node)
+	if fn == nil {
+		return "", false
+	}
+	parts := []string{funcName(fn)}
+	if sel.Kind() == types.MethodVal {
+		parts = append(parts, translateOperand(info, node.X))
+	}
+	for _, arg := range call.Args {
+		parts = append(parts, translateOperand(info, arg))
+	}
+	return strings.Join(parts, " "), true
+}
+
+// translateFuncValueCall calls through a function value.
+func translateFuncValueCall(info *types.Info, call *ast.CallExpr) (string, bool) {
+	fun := ast.Unparen(call.Fun)
+	sig, ok := info.TypeOf(fun).Underlying().(*types.Signature)
+	if !ok {
+		return "", false
+	}
+	switch f := fun.(type) {
+	case *ast.Ident:
+		if _, ok := info.Uses[f].(*types.Var); !ok {
+			return "", false
+		}
+	case *ast.SelectorExpr:
+		if sel := info.Selections[f]; sel == nil || sel.Kind() != types.FieldVal {
+			return "", false
+		}
+	}
+	if sig.Variadic() {
+		diagnose(call.Pos(), "calls through variadic function values are not supported")
+	}
+	parts := []string{applyName(sig), translateOperand(info, fun)}
+	for _, arg := range call.Args {
+		parts = append(parts, translateOperand(info, arg))
+	}
+	return strings.Join(parts, " "), true
+}
+
+func applyName(sig *types.Signature) string {
+	for i, s := range applySigs {
+		if types.Identical(s, sig) {
+			return fmt.Sprintf("__apply%d", i+1)
+		}
+	}
+	applySigs = append(applySigs, sig)
+	return fmt.Sprintf("__apply%d", len(applySigs))
+}
+
+// translateApplyFuncs emits an apply function for every signature called
+// through a function value, dispatching to the functions of that signature
+// used as values.
+func translateApplyFuncs() string {
+	var funcs []string
+	for n, sig := range applySigs {
+		var params, args []string
+		for j := 0; j < sig.Params().Len(); j++ {
+			name := fmt.Sprintf("__a%d", j+1)
+			params = append(params, name+":"+evyType(sig.Params().At(j).Type()).String())
+			args = append(args, name)
+		}
+		result := resultType(sig.Results())
+		var buf strings.Builder
+		buf.WriteString(translateSignature(fmt.Sprintf("__apply%d", n+1), append([]string{"__f:[]any"}, params...), result))
+		var cases []string
+		for _, target := range funcTargets {
+			if !types.Identical(target.sig, sig) {
+				continue
+			}
+			call := []string{target.name}
+			for k, t := range target.bound {
+				call = append(call, fmt.Sprintf("__f[%d].(%s)", k+1, t))
+			}
+			call = append(call, args...)
+			stmt := strings.Join(call, " ")
+			if result != "" {
+				stmt = "return " + stmt
+			} else {
+				stmt += "\nreturn"
+			}
+			cases = append(cases, fmt.Sprintf("if __name == %q\n%s\n", target.name, i(stmt)))
+		}
+		body := useHelper("__panic") + ` "call of nil or unknown function"`
+		if result != "" {
+			zero := "[]"
+			if sig.Results().Len() == 1 {
+				zero = zeroValue(sig.Results().At(0).Type())
+			}
+			body += "\nreturn " + zero
+		}
+		if len(cases) > 0 {
+			body = "__name := __f[0].(string)\n" + strings.Join(cases, "else ") + "end\n" + body
+		}
+		buf.WriteString(i(body))
+		buf.WriteString("\nend\n")
+		funcs = append(funcs, buf.String())
+	}
+	return strings.Join(funcs, "\n")
+}
+
+func init() {
+	addHelpers(map[string]helper{
+		"__func": {src: `
+func __func:[]any parts:any...
+    return parts
+end`},
+	})
+}
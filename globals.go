@@ -0,0 +1,47 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// translateGlobals declares the package-level constants and variables ahead
+// of the functions that use them. Variables with initializers are
+// initialized in the order Go initializes them, which follows their
+// dependencies rather than the source.
+func translateGlobals(info *types.Info, file *ast.File) string {
+	var lines []string
+	idents := map[types.Object]*ast.Ident{}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || (gen.Tok != token.CONST && gen.Tok != token.VAR) {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for _, name := range valueSpec.Names {
+				idents[info.Defs[name]] = name
+			}
+			if gen.Tok == token.CONST || len(valueSpec.Values) == 0 {
+				if line := translateValueSpec(info, valueSpec); line != "" {
+					lines = append(lines, line)
+				}
+			}
+		}
+	}
+	for _, init := range info.InitOrder {
+		var ids []*ast.Ident
+		for _, v := range init.Lhs {
+			ids = append(ids, idents[v])
+		}
+		switch {
+		case len(ids) > 1:
+			lines = append(lines, declareTuple(info, ids, init.Rhs))
+		case ids[0].Name != "_":
+			lines = append(lines, declareValue(info, ids[0], translateExpr(info, init.Rhs)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
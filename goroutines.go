@@ -0,0 +1,1394 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// Goroutines are lowered to resumable state machines. Every function that
+// runs as a goroutine becomes an Evy step function whose locals live in a
+// frame map and whose body is split into numbered blocks at each point
+// where it may block on a channel, sleep or call a function that may. A
+// generated scheduler steps the tasks round-robin until main finishes,
+// which keeps the output deterministic.
+
+// taskFuncs holds the names of the functions run by the scheduler: every
+// target of a go statement, every function that may block, and main when
+// the file starts goroutines.
+var taskFuncs map[string]bool
+
+// blockingFuncs are the task functions that may block. A task calling one
+// steps it in a frame of its own until it returns, see hoistRecvs.
+var blockingFuncs map[string]bool
+
+// plainFuncs are the task functions that are also called outside of a
+// task, which are translated to an ordinary function as well.
+var plainFuncs map[string]bool
+
+// concurrent reports that the file starts goroutines under the scheduler,
+// so that sleeping lets them run.
+var concurrent bool
+
+// frame is the task function being lowered, or nil outside of one.
+var frame *taskFrame
+
+// recvTemps maps the receives and blocking calls hoisted out of a task
+// statement to the frame slot holding their value.
+var recvTemps map[ast.Expr]string
+
+// taskReserved are frame keys used by the scheduler itself.
+var taskReserved = map[string]bool{"fn": true, "pc": true, "done": true}
+
+// collectTasks works out which functions are lowered to step functions.
+// Blocking is followed through the call graph, as a function calling one
+// that blocks has to suspend with it.
+func collectTasks(info *types.Info, file *ast.File) {
+	taskFuncs = map[string]bool{}
+	blockingFuncs = map[string]bool{}
+	plainFuncs = map[string]bool{}
+	recvTemps = map[ast.Expr]string{}
+	goCalls := map[*ast.CallExpr]bool{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		goStmt, ok := node.(*ast.GoStmt)
+		if !ok || sequential {
+			return true
+		}
+		goCalls[goStmt.Call] = true
+		switch fun := goStmt.Call.Fun.(type) {
+		case *ast.Ident:
+			if name := calledFunc(info, fun); name != "" {
+				taskFuncs[name] = true
+			}
+		case *ast.FuncLit:
+			taskFuncs[closures[fun].name] = true
+		}
+		return true
+	})
+	concurrent = len(taskFuncs) > 0
+	var funcDecls []*ast.FuncDecl
+	for _, decl := range file.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Body != nil {
+			funcDecls = append(funcDecls, funcDecl)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, funcDecl := range funcDecls {
+			name := funcDecl.Name.Name
+			if funcDecl.Recv == nil && !blockingFuncs[name] && mayBlock(info, funcDecl.Body) {
+				blockingFuncs[name] = true
+				taskFuncs[name] = true
+				changed = true
+			}
+		}
+	}
+	if concurrent {
+		taskFuncs["main"] = true
+	}
+	// A task hoists its calls of blocking functions; every other call of a
+	// task function needs the ordinary function, including the calls in
+	// the ordinary functions this adds.
+	plainCalls := func(inTask bool, body *ast.BlockStmt) (added bool) {
+		ast.Inspect(body, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.CallExpr:
+				name := calledFunc(info, n.Fun)
+				if taskFuncs[name] && !plainFuncs[name] && !goCalls[n] && !(inTask && blockingFuncs[name]) {
+					plainFuncs[name] = true
+					added = true
+				}
+			}
+			return true
+		})
+		return added
+	}
+	for _, c := range closureOrder {
+		plainCalls(taskFuncs[c.name], c.lit.Body)
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, funcDecl := range funcDecls {
+			name := funcDecl.Name.Name
+			inTask := funcDecl.Recv == nil && taskFuncs[name] && !plainFuncs[name]
+			changed = plainCalls(inTask, funcDecl.Body) || changed
+		}
+	}
+}
+
+// calledFunc returns the name of the package-level function expr denotes,
+// or "".
+func calledFunc(info *types.Info, expr ast.Expr) string {
+	id, ok := ast.Unparen(expr).(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	if fn, ok := info.Uses[id].(*types.Func); ok && fn.Pkg() != nil && fn.Parent() == fn.Pkg().Scope() {
+		return fn.Name()
+	}
+	return ""
+}
+
+// mayBlock reports whether node may suspend the goroutine executing it: it
+// blocks on a channel, sleeps while goroutines run, waits for a WaitGroup,
+// or calls a function that may block.
+func mayBlock(info *types.Info, node ast.Node) bool {
+	if blocksOnChan(info, node) {
+		return true
+	}
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit, *ast.GoStmt:
+			return false
+		case *ast.CallExpr:
+			found = found || blockingFuncs[calledFunc(info, n.Fun)] || isSleep(info, n) || isWait(info, n)
+		}
+		return !found
+	})
+	return found
+}
+
+// isSleep reports whether call is a time.Sleep that lets goroutines run.
+func isSleep(info *types.Info, call *ast.CallExpr) bool {
+	return concurrent && isPkgFunc(info, call.Fun, "time", "Sleep")
+}
+
+// blocksOnChan reports whether node contains a channel operation that can
+// suspend the goroutine executing it.
+func blocksOnChan(info *types.Info, node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.SendStmt, *ast.SelectStmt:
+			found = true
+		case *ast.UnaryExpr:
+			found = found || n.Op == token.ARROW && !isTimerRecv(info, n)
+		case *ast.RangeStmt:
+			_, isChan := info.TypeOf(n.X).Underlying().(*types.Chan)
+			found = found || isChan && timerInterval(info, n.X) == nil
+		}
+		return !found
+	})
+	return found
+}
+
+type taskFrame struct {
+	info   *types.Info
+	blocks []*strings.Builder
+	cur    int
+	names  map[types.Object]string
+	used   map[string]bool
+	label  string
+	loops  []jumpTargets
+	fall   int
+	defers []*ast.DeferStmt
+}
+
+// jumpTargets are the blocks a break or continue inside a loop, switch or
+// select jumps to. cont is -1 for statements that cannot be continued.
+type jumpTargets struct {
+	label     string
+	brk, cont int
+}
+
+func newTaskFrame(info *types.Info) *taskFrame {
+	f := &taskFrame{info: info, names: map[types.Object]string{}, used: map[string]bool{}}
+	f.cur = f.newBlock()
+	return f
+}
+
+// declare allocates a frame slot for a Go variable, renaming it when the
+// name is already taken by a shadowed variable or the scheduler.
+func (f *taskFrame) declare(obj types.Object) string {
+	if name, ok := f.names[obj]; ok {
+		return name
+	}
+	base := localName(obj)
+	name := base
+	for suffix := 1; f.used[name] || taskReserved[name]; suffix++ {
+		name = fmt.Sprintf("%s_%d", base, suffix)
+	}
+	f.used[name] = true
+	f.names[obj] = name
+	return name
+}
+
+func (f *taskFrame) newBlock() int {
+	f.blocks = append(f.blocks, &strings.Builder{})
+	return len(f.blocks) - 1
+}
+
+func (f *taskFrame) emit(format string, args ...any) {
+	if s := fmt.Sprintf(format, args...); strings.TrimSpace(s) != "" {
+		f.blocks[f.cur].WriteString(n(s))
+	}
+}
+
+// jump ends the current block with a transfer to target. Anything lowered
+// afterwards lands in a fresh, unreachable block.
+func (f *taskFrame) jump(target int) {
+	f.emit("%s __t %d", useHelper("__goto"), target)
+	f.cur = f.newBlock()
+}
+
+// next ends the current block with a transfer to a new block and continues
+// there; used ahead of operations that may have to be retried.
+func (f *taskFrame) next() {
+	b := f.newBlock()
+	f.jump(b)
+	f.cur = b
+}
+
+func (f *taskFrame) branch(cond string, yes, no int) {
+	f.emit("if %s\n    %s __t %d\nelse\n    %s __t %d\nend", cond, useHelper("__goto"), yes, useHelper("__goto"), no)
+	f.cur = f.newBlock()
+}
+
+// suspend returns from the step function, leaving pc on the current block,
+// unless cond holds.
+func (f *taskFrame) suspendUnless(cond string) {
+	f.emit("if !(%s)\n    return\nend", cond)
+}
+
+func (f *taskFrame) temp() string {
+	return newTemp("v")
+}
+
+func (f *taskFrame) lowerStmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		f.lowerStmt(stmt)
+	}
+}
+
+func (f *taskFrame) lowerStmt(stmt ast.Stmt) {
+	info := f.info
+	switch s := stmt.(type) {
+	case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.BlockStmt:
+		if !f.needsLowering(s) {
+			f.emit("%s", translateStmt(info, s))
+			return
+		}
+	}
+	label := f.label
+	f.label = ""
+	switch s := stmt.(type) {
+	case *ast.BlockStmt:
+		f.lowerStmts(s.List)
+	case *ast.LabeledStmt:
+		f.label = s.Label.Name
+		f.lowerStmt(s.Stmt)
+	case *ast.ReturnStmt:
+		// A blocking function called by a task leaves its results in
+		// the frame, see call.
+		f.hoistRecvs(s)
+		if ret := translateReturnStmt(info, s); ret != "return" {
+			f.emit("__t.__ret = %s", strings.TrimPrefix(ret, "return "))
+		}
+		f.exit(s.Pos())
+	case *ast.BranchStmt:
+		f.lowerBranch(s)
+	case *ast.IfStmt:
+		f.lowerIf(s)
+	case *ast.ForStmt:
+		f.lowerFor(s, label)
+	case *ast.RangeStmt:
+		f.lowerRange(s, label)
+	case *ast.SwitchStmt:
+		f.lowerSwitch(s, label)
+	case *ast.SelectStmt:
+		f.lowerSelect(s, label)
+	case *ast.SendStmt:
+		f.hoistRecvs(s)
+		c, v := f.temp(), f.temp()
+		f.emit("__t.%s = %s\n__t.%s = %s", c, translateExpr(info, s.Chan), v, translateExpr(info, s.Value))
+		f.next()
+		f.suspendUnless(fmt.Sprintf("%s __t __t.%s.({}any) __t.%s", useHelper("__send"), c, v))
+	case *ast.GoStmt:
+		f.emit("%s", translateGoStmt(info, s))
+	case *ast.DeferStmt:
+		if sel, ok := s.Call.Fun.(*ast.SelectorExpr); ok && isSyncType(info.TypeOf(sel.X)) {
+			f.defers = append(f.defers, s)
+		} else {
+			f.emit("%s", translateStmt(info, s))
+		}
+	case *ast.AssignStmt, *ast.IncDecStmt, *ast.ExprStmt, *ast.DeclStmt:
+		f.lowerSimple(s)
+	case *ast.EmptyStmt:
+	default:
+		diagnose(s.Pos(), "%T is not supported inside a goroutine", s)
+		f.emit("%s", translateStmt(info, s))
+	}
+}
+
+// exit ends the task at pos, first making the deferred sync calls that
+// precede it, as a WaitGroup must not be done before its task is.
+func (f *taskFrame) exit(pos token.Pos) {
+	for n := len(f.defers) - 1; n >= 0; n-- {
+		if f.defers[n].Pos() < pos {
+			f.emit("%s", translateStmt(f.info, f.defers[n]))
+		}
+	}
+	f.emit("%s __t\nreturn", useHelper("__done"))
+	f.cur = f.newBlock()
+}
+
+// needsLowering reports whether a compound statement has to be split into
+// blocks: it blocks, returns, or breaks out to a statement enclosing it.
+// Otherwise it is emitted as ordinary structured Evy inside one block.
+func (f *taskFrame) needsLowering(stmt ast.Stmt) bool {
+	found := mayBlock(f.info, stmt)
+	labels := map[string]bool{}
+	var stack []ast.Node
+	loops, breakables := 0, 0
+	ast.Inspect(stmt, func(node ast.Node) bool {
+		if found {
+			return false
+		}
+		if node == nil {
+			switch stack[len(stack)-1].(type) {
+			case *ast.ForStmt, *ast.RangeStmt:
+				loops--
+				breakables--
+			case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				breakables--
+			}
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		switch s := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ForStmt, *ast.RangeStmt:
+			loops++
+			breakables++
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			breakables++
+		case *ast.LabeledStmt:
+			labels[s.Label.Name] = true
+		case *ast.ReturnStmt, *ast.GoStmt:
+			found = true
+		case *ast.BranchStmt:
+			switch {
+			case s.Label != nil:
+				found = !labels[s.Label.Name]
+			case s.Tok == token.BREAK:
+				found = breakables == 0
+			case s.Tok == token.CONTINUE:
+				found = loops == 0
+			case s.Tok == token.GOTO:
+				found = true
+			}
+		}
+		stack = append(stack, node)
+		return true
+	})
+	return found
+}
+
+// hoistRecvs moves every receive and call of a blocking function in node
+// into blocks of its own ahead of the statement, innermost first, and
+// records where each value is.
+func (f *taskFrame) hoistRecvs(node ast.Node) {
+	var hoisted []ast.Expr
+	var stack []ast.Node
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			switch e := stack[len(stack)-1].(type) {
+			case *ast.UnaryExpr:
+				if e.Op == token.ARROW && !isTimerRecv(f.info, e) {
+					hoisted = append(hoisted, e)
+				}
+			case *ast.CallExpr:
+				if blockingFuncs[calledFunc(f.info, e.Fun)] {
+					hoisted = append(hoisted, e)
+				}
+			}
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		switch n.(type) {
+		case *ast.FuncLit, *ast.GoStmt:
+			return false
+		}
+		stack = append(stack, n)
+		return true
+	})
+	for _, expr := range hoisted {
+		if call, ok := expr.(*ast.CallExpr); ok {
+			f.call(call)
+			continue
+		}
+		recv := expr.(*ast.UnaryExpr)
+		c, v := f.temp(), f.temp()
+		f.emit("__t.%s = %s", c, translateExpr(f.info, recv.X))
+		f.next()
+		f.recv("__t."+c+".({}any)", chanElem(f.info, recv))
+		f.emit("__t.%s = __t.__val", v)
+		recvTemps[recv] = "__t." + v + ".(" + evyType(chanElem(f.info, recv)).String() + ")"
+	}
+}
+
+// call emits a call of a blocking function, which is stepped in a frame
+// of its own until it returns, suspending the task whenever it does.
+func (f *taskFrame) call(call *ast.CallExpr) {
+	name := calledFunc(f.info, call.Fun)
+	sig := f.info.TypeOf(call.Fun).(*types.Signature)
+	if sig.Variadic() {
+		diagnose(call.Pos(), "calling variadic %s inside a goroutine is not supported", name)
+	}
+	c, task := f.temp(), newTemp("g")
+	f.emit("%s := %s %q\n%s__t.%s = %s", task, useHelper("__task"), name, taskArgs(f.info, task, nil, sig, call.Args), c, task)
+	f.next()
+	callee := "__t." + c + ".({}any)"
+	f.emit("__step_%s %s", name, callee)
+	f.suspendUnless(callee + ".done.(bool)")
+	switch sig.Results().Len() {
+	case 0:
+		recvTemps[call] = ""
+	case 1:
+		recvTemps[call] = callee + ".__ret.(" + evyType(sig.Results().At(0).Type()).String() + ")"
+	default:
+		recvTemps[call] = callee + ".__ret.([]any)"
+	}
+}
+
+// wait emits a WaitGroup.Wait, which suspends the task until the counter
+// drops to zero.
+func (f *taskFrame) wait(call *ast.CallExpr) {
+	wg := f.temp()
+	f.emit("__t.%s = %s", wg, translateExpr(f.info, call.Fun.(*ast.SelectorExpr).X))
+	f.next()
+	f.suspendUnless(fmt.Sprintf("__t.%s.({}any).n.(num) == 0", wg))
+}
+
+// sleep emits a time.Sleep, which suspends the task until it has passed
+// so that other tasks run meanwhile.
+func (f *taskFrame) sleep(call *ast.CallExpr) {
+	f.hoistRecvs(call)
+	d := f.temp()
+	f.emit("__t.%s = %s", d, translateExpr(f.info, call.Args[0]))
+	f.next()
+	f.suspendUnless(fmt.Sprintf("%s __t __t.%s.(num)", useHelper("__sleep"), d))
+}
+
+// recv emits a receive from ch that suspends until a value is available,
+// leaving it in __t.__val and the ok flag in __t.__ok.
+func (f *taskFrame) recv(ch string, elem types.Type) {
+	f.suspendUnless(useHelper("__ready_recv") + " " + ch)
+	f.emit("%s __t %s %s", useHelper("__take"), ch, zeroValue(elem))
+}
+
+func (f *taskFrame) lowerSimple(stmt ast.Stmt) {
+	info := f.info
+	if expr, ok := stmt.(*ast.ExprStmt); ok {
+		if call, ok := ast.Unparen(expr.X).(*ast.CallExpr); ok && isSleep(info, call) {
+			f.sleep(call)
+			return
+		} else if ok && isWait(info, call) {
+			f.wait(call)
+			return
+		}
+	}
+	if assign, ok := stmt.(*ast.AssignStmt); ok && len(assign.Lhs) == 2 && len(assign.Rhs) == 1 {
+		if recv, ok := ast.Unparen(assign.Rhs[0]).(*ast.UnaryExpr); ok && recv.Op == token.ARROW {
+			f.hoistRecvs(recv.X)
+			c := f.temp()
+			f.emit("__t.%s = %s", c, translateExpr(info, recv.X))
+			f.next()
+			f.recv("__t."+c+".({}any)", chanElem(info, recv))
+			f.declareLhs(assign)
+			f.assignRecv(assign.Lhs[0], assign.Lhs[1])
+			return
+		}
+	}
+	f.hoistRecvs(stmt)
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		if isClosureBinding(info, s) {
+			return
+		}
+		f.declareLhs(s)
+		f.emit("%s", translateAssignStmt(info, s))
+	case *ast.DeclStmt:
+		gen, ok := s.Decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR && gen.Tok != token.CONST || isClosureBinding(info, s) {
+			f.emit("%s", translateStmt(info, s))
+			return
+		}
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				f.declare(info.Defs[name])
+			}
+			f.emit("%s", translateValueSpec(info, spec.(*ast.ValueSpec)))
+		}
+	case *ast.ExprStmt:
+		if _, ok := recvTemps[ast.Unparen(s.X)]; !ok {
+			f.emit("%s", translateExprStmt(info, s))
+		}
+	default:
+		f.emit("%s", translateStmt(info, s))
+	}
+}
+
+// compoundOps maps compound assignment tokens to their binary operator.
+var compoundOps = map[token.Token]token.Token{
+	token.ADD_ASSIGN: token.ADD,
+	token.SUB_ASSIGN: token.SUB,
+	token.MUL_ASSIGN: token.MUL,
+	token.QUO_ASSIGN: token.QUO,
+	token.REM_ASSIGN: token.REM,
+
+	token.AND_ASSIGN:     token.AND,
+	token.OR_ASSIGN:      token.OR,
+	token.XOR_ASSIGN:     token.XOR,
+	token.AND_NOT_ASSIGN: token.AND_NOT,
+	token.SHL_ASSIGN:     token.SHL,
+	token.SHR_ASSIGN:     token.SHR,
+}
+
+func (f *taskFrame) declareLhs(assign *ast.AssignStmt) {
+	if assign.Tok != token.DEFINE {
+		return
+	}
+	for _, lhs := range assign.Lhs {
+		if obj := f.info.Defs[lhs.(*ast.Ident)]; obj != nil {
+			f.declare(obj)
+		}
+	}
+}
+
+// assignRecv stores the value and ok flag left by a receive.
+func (f *taskFrame) assignRecv(value, ok ast.Expr) {
+	for _, target := range []struct {
+		lhs  ast.Expr
+		slot string
+	}{{value, "__val"}, {ok, "__ok"}} {
+		if target.lhs == nil {
+			continue
+		}
+		if id, isIdent := target.lhs.(*ast.Ident); isIdent && id.Name == "_" {
+			continue
+		}
+		value := fmt.Sprintf("__t.%s.(%s)", target.slot, evyType(f.info.TypeOf(target.lhs)))
+		if id, isIdent := target.lhs.(*ast.Ident); isIdent && f.info.Defs[id] != nil {
+			f.emit("%s", declareVar(f.info, id, value))
+		} else {
+			f.emit("%s = %s", translateLhs(f.info, target.lhs), value)
+		}
+	}
+}
+
+func (f *taskFrame) lowerBranch(s *ast.BranchStmt) {
+	for i := len(f.loops) - 1; i >= 0; i-- {
+		targets := f.loops[i]
+		if s.Label != nil && s.Label.Name != targets.label {
+			continue
+		}
+		switch {
+		case s.Tok == token.BREAK:
+			f.jump(targets.brk)
+			return
+		case s.Tok == token.CONTINUE && targets.cont >= 0:
+			f.jump(targets.cont)
+			return
+		}
+	}
+	if s.Tok == token.FALLTHROUGH {
+		f.jump(f.fall)
+		return
+	}
+	diagnose(s.Pos(), "%s is not supported inside a goroutine", s.Tok)
+}
+
+func (f *taskFrame) lowerIf(s *ast.IfStmt) {
+	if s.Init != nil {
+		f.lowerStmt(s.Init)
+	}
+	f.hoistRecvs(s.Cond)
+	then, els, end := f.newBlock(), f.newBlock(), f.newBlock()
+	f.branch(translateExpr(f.info, s.Cond), then, els)
+	f.cur = then
+	f.lowerStmts(s.Body.List)
+	f.jump(end)
+	f.cur = els
+	if s.Else != nil {
+		f.lowerStmt(s.Else)
+	}
+	f.jump(end)
+	f.cur = end
+}
+
+func (f *taskFrame) lowerFor(s *ast.ForStmt, label string) {
+	info := f.info
+	if s.Init != nil {
+		f.lowerStmt(s.Init)
+	}
+	head, body, post, exit := f.newBlock(), f.newBlock(), f.newBlock(), f.newBlock()
+	f.jump(head)
+	f.cur = head
+	if s.Cond != nil {
+		f.hoistRecvs(s.Cond)
+		f.branch(translateExpr(f.info, s.Cond), body, exit)
+	} else {
+		f.jump(body)
+	}
+	f.cur = body
+	f.loops = append(f.loops, jumpTargets{label, exit, post})
+	f.lowerStmts(s.Body.List)
+	f.loops = f.loops[:len(f.loops)-1]
+	f.jump(post)
+	f.cur = post
+	if init, ok := s.Init.(*ast.AssignStmt); ok && init.Tok == token.DEFINE {
+		// As in Go 1.22, the next iteration gets a copy of a boxed loop
+		// variable before the post statement runs.
+		for _, lhs := range init.Lhs {
+			if obj := info.Defs[lhs.(*ast.Ident)]; boxes[obj] != "" {
+				slot := "__t." + f.names[obj]
+				f.emit("%s = {v:%s.({}%s).v}", slot, slot, evyType(obj.Type()))
+			}
+		}
+	}
+	if s.Post != nil {
+		f.lowerStmt(s.Post)
+	}
+	f.jump(head)
+	f.cur = exit
+}
+
+func (f *taskFrame) lowerRange(s *ast.RangeStmt, label string) {
+	info := f.info
+	f.hoistRecvs(s.X)
+	if s.Tok == token.DEFINE {
+		for _, e := range []ast.Expr{s.Key, s.Value} {
+			if id, ok := e.(*ast.Ident); ok && info.Defs[id] != nil {
+				f.declare(info.Defs[id])
+			}
+		}
+	}
+	x := f.temp()
+	head, body, post, exit := f.newBlock(), f.newBlock(), f.newBlock(), f.newBlock()
+	xType := info.TypeOf(s.X)
+	if interval := timerInterval(info, s.X); interval != nil {
+		// See translateTimerRange.
+		f.emit("__t.%s = %s", x, translateExpr(info, interval))
+		f.jump(head)
+		f.cur = head
+		f.emit("sleep __t.%s.(num)", x)
+		switch {
+		case s.Key == nil || isBlank(s.Key):
+		case s.Tok == token.DEFINE:
+			f.emit("%s", declareVar(info, s.Key.(*ast.Ident), "(now)"))
+		default:
+			f.emit("%s = (now)", translateLhs(info, s.Key))
+		}
+		f.lowerLoopBody(s.Body, label, head, exit)
+		f.cur = exit
+		return
+	}
+	if ch, ok := xType.Underlying().(*types.Chan); ok {
+		f.emit("__t.%s = %s", x, translateExpr(info, s.X))
+		f.jump(head)
+		f.cur = head
+		f.recv("__t."+x+".({}any)", ch.Elem())
+		f.branch("__t.__ok.(bool)", body, exit)
+		f.cur = body
+		f.assignRecv(s.Key, nil)
+		f.lowerLoopBody(s.Body, label, head, exit)
+		f.cur = exit
+		return
+	}
+	idx, keys := f.temp(), ""
+	f.emit("__t.%s = %s\n__t.%s = 0", x, translateExpr(info, s.X), idx)
+	length := fmt.Sprintf("(len __t.%s.(%s))", x, evyType(xType))
+	switch u := xType.Underlying().(type) {
+	case *types.Basic:
+		if u.Info()&types.IsInteger != 0 {
+			length = fmt.Sprintf("__t.%s.(num)", x)
+		}
+	case *types.Map:
+		keys = f.temp()
+		local := newTemp("keys")
+		f.emit("%s:[]string\nfor __k := range __t.%s.(%s)\n    %s = %s + [__k]\nend\n__t.%s = %s",
+			local, x, evyType(xType), local, local, keys, local)
+		length = fmt.Sprintf("(len __t.%s.([]string))", keys)
+	}
+	f.jump(head)
+	f.cur = head
+	f.branch(fmt.Sprintf("__t.%s.(num) < %s", idx, length), body, exit)
+	f.cur = body
+	key := fmt.Sprintf("__t.%s.(num)", idx)
+	if keys != "" {
+		key = fmt.Sprintf("__t.%s.([]string)[%s]", keys, key)
+	}
+	value := fmt.Sprintf("__t.%s.(%s)[%s]", x, evyType(xType), key)
+	for _, target := range []struct {
+		lhs   ast.Expr
+		value string
+	}{{s.Key, key}, {s.Value, value}} {
+		switch {
+		case target.lhs == nil || isBlank(target.lhs):
+		case s.Tok == token.DEFINE:
+			f.emit("%s", declareVar(info, target.lhs.(*ast.Ident), target.value))
+		default:
+			f.emit("%s = %s", translateLhs(info, target.lhs), target.value)
+		}
+	}
+	f.lowerLoopBody(s.Body, label, post, exit)
+	f.cur = post
+	f.emit("__t.%s = __t.%s.(num) + 1", idx, idx)
+	f.jump(head)
+	f.cur = exit
+}
+
+func (f *taskFrame) lowerLoopBody(body *ast.BlockStmt, label string, cont, exit int) {
+	f.loops = append(f.loops, jumpTargets{label, exit, cont})
+	f.lowerStmts(body.List)
+	f.loops = f.loops[:len(f.loops)-1]
+	f.jump(cont)
+}
+
+func (f *taskFrame) lowerSwitch(s *ast.SwitchStmt, label string) {
+	info := f.info
+	if s.Init != nil {
+		f.lowerStmt(s.Init)
+	}
+	for _, stmt := range s.Body.List {
+		for _, e := range stmt.(*ast.CaseClause).List {
+			f.hoistRecvs(e)
+		}
+	}
+	tag := ""
+	if s.Tag != nil {
+		f.hoistRecvs(s.Tag)
+		tag = f.temp()
+		f.emit("__t.%s = %s", tag, translateExpr(info, s.Tag))
+	}
+	end := f.newBlock()
+	bodies := make([]int, len(s.Body.List))
+	var dispatch strings.Builder
+	dflt := end
+	for i, stmt := range s.Body.List {
+		clause := stmt.(*ast.CaseClause)
+		bodies[i] = f.newBlock()
+		if clause.List == nil {
+			dflt = bodies[i]
+			continue
+		}
+		var conds []string
+		for _, e := range clause.List {
+			if tag == "" {
+				conds = append(conds, translateExpr(info, e))
+			} else {
+				conds = append(conds, fmt.Sprintf("__t.%s.(%s) == %s", tag, evyType(info.TypeOf(s.Tag)), translateExpr(info, e)))
+			}
+		}
+		keyword := "else if"
+		if dispatch.Len() == 0 {
+			keyword = "if"
+		}
+		fmt.Fprintf(&dispatch, "%s %s\n    %s __t %d\n", keyword, strings.Join(conds, " or "), useHelper("__goto"), bodies[i])
+	}
+	if dispatch.Len() == 0 {
+		f.jump(dflt)
+	} else {
+		fmt.Fprintf(&dispatch, "else\n    %s __t %d\nend", useHelper("__goto"), dflt)
+		f.emit("%s", dispatch.String())
+		f.cur = f.newBlock()
+	}
+	f.loops = append(f.loops, jumpTargets{label, end, -1})
+	for i, stmt := range s.Body.List {
+		f.cur = bodies[i]
+		f.fall = end
+		if i+1 < len(bodies) {
+			f.fall = bodies[i+1]
+		}
+		f.lowerStmts(stmt.(*ast.CaseClause).Body)
+		f.jump(end)
+	}
+	f.loops = f.loops[:len(f.loops)-1]
+	f.cur = end
+}
+
+// lowerSelect evaluates every channel and sent value on entry, as Go does,
+// then polls the cases in source order each time the task is stepped. The
+// first ready case wins, so the choice is deterministic rather than random.
+// A send on an unbuffered channel commits to its case once the value has
+// been handed over and then waits for it to be received. A timer case such
+// as <-time.After(d) becomes ready once d has passed since entry.
+func (f *taskFrame) lowerSelect(s *ast.SelectStmt, label string) {
+	info := f.info
+	type selectCase struct {
+		clause   *ast.CommClause
+		ch, sent string // ch holds the deadline of a timer case
+		timer    bool
+		body     int
+	}
+	var cases []selectCase
+	dflt := -1
+	for _, stmt := range s.Body.List {
+		clause := stmt.(*ast.CommClause)
+		sc := selectCase{clause: clause, body: f.newBlock()}
+		switch comm := clause.Comm.(type) {
+		case nil:
+			dflt = sc.body
+		case *ast.SendStmt:
+			f.hoistRecvs(comm)
+			sc.ch, sc.sent = f.temp(), f.temp()
+			f.emit("__t.%s = %s\n__t.%s = %s", sc.ch, translateExpr(info, comm.Chan), sc.sent, translateExpr(info, comm.Value))
+		default:
+			recv := commRecv(comm)
+			if interval := timerInterval(info, recv.X); interval != nil {
+				f.hoistRecvs(interval)
+				sc.ch, sc.timer = f.temp(), true
+				f.emit("__t.%s = (now) + %s", sc.ch, translateOperand(info, interval))
+				break
+			}
+			f.hoistRecvs(recv.X)
+			sc.ch = f.temp()
+			f.emit("__t.%s = %s", sc.ch, translateExpr(info, recv.X))
+		}
+		cases = append(cases, sc)
+	}
+	f.next()
+	end := f.newBlock()
+	keyword := "if"
+	var poll strings.Builder
+	for _, sc := range cases {
+		if sc.ch == "" {
+			continue
+		}
+		ch := "__t." + sc.ch + ".({}any)"
+		if sc.timer {
+			fmt.Fprintf(&poll, "%s %s __t __t.%s.(num)\n    %s __t %d\n", keyword, useHelper("__timer_ready"), sc.ch, useHelper("__goto"), sc.body)
+		} else if sc.sent != "" {
+			sendBlock := f.newBlock()
+			fmt.Fprintf(&poll, "%s %s %s\n    %s __t %d\n", keyword, useHelper("__ready_send"), ch, useHelper("__goto"), sendBlock)
+			saved := f.cur
+			f.cur = sendBlock
+			f.suspendUnless(fmt.Sprintf("%s __t %s __t.%s", useHelper("__send"), ch, sc.sent))
+			f.jump(sc.body)
+			f.cur = saved
+		} else {
+			recv := commRecv(sc.clause.Comm)
+			fmt.Fprintf(&poll, "%s %s %s\n    %s __t %s %s\n    %s __t %d\n", keyword, useHelper("__ready_recv"), ch,
+				useHelper("__take"), ch, zeroValue(chanElem(info, recv)), useHelper("__goto"), sc.body)
+		}
+		keyword = "else if"
+	}
+	switch {
+	case keyword == "if" && dflt < 0:
+		f.emit("return")
+	case keyword == "if":
+		f.jump(dflt)
+	case dflt < 0:
+		f.emit("%send\nreturn", poll.String())
+	default:
+		fmt.Fprintf(&poll, "else\n    %s __t %d\nend", useHelper("__goto"), dflt)
+		f.emit("%s", poll.String())
+	}
+	f.cur = f.newBlock()
+	f.loops = append(f.loops, jumpTargets{label, end, -1})
+	for _, sc := range cases {
+		f.cur = sc.body
+		if assign, ok := sc.clause.Comm.(*ast.AssignStmt); ok {
+			f.declareLhs(assign)
+			var okLhs ast.Expr
+			if len(assign.Lhs) > 1 {
+				okLhs = assign.Lhs[1]
+			}
+			f.assignRecv(assign.Lhs[0], okLhs)
+		}
+		f.lowerStmts(sc.clause.Body)
+		f.jump(end)
+	}
+	f.loops = f.loops[:len(f.loops)-1]
+	f.cur = end
+}
+
+// chanElem returns the element type of the channel a receive reads from.
+// The receive itself may have a tuple type when used in comma-ok form.
+func chanElem(info *types.Info, recv *ast.UnaryExpr) types.Type {
+	return info.TypeOf(recv.X).Underlying().(*types.Chan).Elem()
+}
+
+// commRecv returns the receive expression of a select case.
+func commRecv(comm ast.Stmt) *ast.UnaryExpr {
+	switch s := comm.(type) {
+	case *ast.ExprStmt:
+		return ast.Unparen(s.X).(*ast.UnaryExpr)
+	case *ast.AssignStmt:
+		return ast.Unparen(s.Rhs[0]).(*ast.UnaryExpr)
+	}
+	return nil
+}
+
+func isBlank(expr ast.Expr) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+// translateTaskDecl lowers a task function to its step function. A lifted
+// closure receives its free variables ahead of its parameters, and the
+// results are left in the frame's __ret.
+func translateTaskDecl(info *types.Info, name string, free []*types.Var, typ *ast.FuncType, body *ast.BlockStmt) string {
+	frame = newTaskFrame(info)
+	outer, outerTypes := funcResults, resultTypes
+	defer func() {
+		frame, recvTemps = nil, map[ast.Expr]string{}
+		funcResults, resultTypes = outer, outerTypes
+	}()
+	for _, v := range free {
+		frame.declare(v)
+	}
+	var ids []ast.Expr
+	for _, field := range typ.Params.List {
+		for _, id := range field.Names {
+			frame.declare(info.Defs[id])
+			ids = append(ids, id)
+		}
+	}
+	frame.emit("%s", boxPrefix(info, ids...))
+	if typ.Results != nil {
+		for _, field := range typ.Results.List {
+			for _, id := range field.Names {
+				frame.declare(info.Defs[id])
+			}
+		}
+	}
+	frame.emit("%s", joinLines(declareResults(info, typ)))
+	frame.lowerStmts(body.List)
+	frame.exit(body.End())
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "func __step_%s __t:{}any\n", name)
+	buf.WriteString("    while true\n")
+	buf.WriteString("        __pc := __t.pc.(num)\n")
+	for pc, block := range frame.blocks {
+		// Blocks left empty are never jumped to.
+		if block.Len() == 0 {
+			continue
+		}
+		keyword := "else if"
+		if pc == 0 {
+			keyword = "if"
+		}
+		fmt.Fprintf(&buf, "        %s __pc == %d\n", keyword, pc)
+		buf.WriteString(i(i(i(block.String()))))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("        end\n    end\nend\n")
+	if name == "main" {
+		fmt.Fprintf(&buf, "%s (%s \"main\")\n%s\n", useHelper("__go"), useHelper("__task"), useHelper("__run"))
+	}
+	return buf.String()
+}
+
+// translateDispatch generates the function the scheduler uses to step a
+// task, since Evy cannot call a function through a value.
+func translateDispatch() string {
+	if len(taskFuncs) == 0 || !helperUsed["__run"] {
+		return ""
+	}
+	var names []string
+	for name := range taskFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf strings.Builder
+	buf.WriteString("func __dispatch t:{}any\n    fn := t.fn.(string)\n")
+	keyword := "if"
+	for _, name := range names {
+		fmt.Fprintf(&buf, "    %s fn == %q\n        __step_%s t\n", keyword, name, name)
+		keyword = "else if"
+	}
+	buf.WriteString("    end\nend\n")
+	return buf.String()
+}
+
+func translateGoStmt(info *types.Info, node *ast.GoStmt) string {
+	if sequential {
+		return translateExpr(info, node.Call)
+	}
+	name := ""
+	var c *closure
+	switch fun := node.Call.Fun.(type) {
+	case *ast.Ident:
+		name = fun.Name
+	case *ast.FuncLit:
+		c = closures[fun]
+		name = c.name
+	}
+	if !taskFuncs[name] {
+		diagnose(node.Pos(), "go statement must call a function declared in this file")
+		return ""
+	}
+	sig := info.TypeOf(node.Call.Fun).(*types.Signature)
+	if sig.Variadic() {
+		diagnose(node.Pos(), "variadic goroutine %s is not supported", name)
+	}
+	task := newTemp("g")
+	return fmt.Sprintf("%s := %s %q\n%s%s %s", task, useHelper("__task"), name, taskArgs(info, task, c, sig, node.Call.Args), useHelper("__go"), task)
+}
+
+// taskArgs returns the assignments passing the arguments of a call to the
+// frame of a new task, and for a closure the values it captures, in the
+// slots translateTaskDecl declares for them.
+func taskArgs(info *types.Info, task string, c *closure, sig *types.Signature, args []ast.Expr) string {
+	keys := newTaskFrame(info)
+	var buf strings.Builder
+	if c != nil {
+		for i, arg := range captureArgs(c) {
+			fmt.Fprintf(&buf, "%s.%s = %s\n", task, keys.declare(c.free[i]), arg)
+		}
+	}
+	for i, arg := range args {
+		param := sig.Params().At(i)
+		key := keys.declare(param)
+		if param.Name() != "" && param.Name() != "_" && !isErasedSync(param.Type()) {
+			fmt.Fprintf(&buf, "%s.%s = %s\n", task, key, translateExpr(info, arg))
+		}
+	}
+	return buf.String()
+}
+
+// translateChanCall translates the builtins that operate on channels.
+func translateChanCall(info *types.Info, call *ast.CallExpr) (string, bool) {
+	id, ok := call.Fun.(*ast.Ident)
+	if !ok || len(call.Args) == 0 {
+		return "", false
+	}
+	if _, ok := info.Uses[id].(*types.Builtin); !ok {
+		return "", false
+	}
+	if _, ok := info.TypeOf(call.Args[0]).Underlying().(*types.Chan); !ok {
+		return "", false
+	}
+	switch id.Name {
+	case "make":
+		size := "0"
+		if len(call.Args) > 1 {
+			size = translateExpr(info, call.Args[1])
+		}
+		return "(" + useHelper("__chan") + " " + size + ")", true
+	case "close":
+		return useHelper("__close") + " " + translateExpr(info, call.Args[0]), true
+	case "len":
+		return "(len " + translateExpr(info, call.Args[0]) + ".buf.([]any))", true
+	case "cap":
+		return translateExpr(info, call.Args[0]) + ".cap.(num)", true
+	}
+	return "", false
+}
+
+// translateRecv translates a receive. Inside a goroutine the receive has
+// already been hoisted into its own block; elsewhere nothing else can run
+// while it waits, so it has to succeed immediately.
+func translateRecv(info *types.Info, node *ast.UnaryExpr) string {
+	if temp, ok := recvTemps[node]; ok {
+		return temp
+	}
+	elem := chanElem(info, node)
+	return fmt.Sprintf("(%s %s %s).(%s)", useHelper("__recv_now"), translateExpr(info, node.X), zeroValue(elem), evyType(elem))
+}
+
+func translateChanRange(info *types.Info, node *ast.RangeStmt) string {
+	var buf strings.Builder
+	elem := info.TypeOf(node.X).Underlying().(*types.Chan).Elem()
+	v := newTemp("v")
+	fmt.Fprintf(&buf, "for %s := range (%s %s)\n", v, useHelper("__drain"), translateExpr(info, node.X))
+	if node.Key != nil && !isBlank(node.Key) {
+		op := "="
+		if node.Tok == token.DEFINE {
+			op = ":="
+		}
+		buf.WriteString(i(fmt.Sprintf("%s %s %s.(%s)", translateLhs(info, node.Key), op, v, evyType(elem))))
+		buf.WriteString("\n")
+	}
+	buf.WriteString(translateBlockStmt(info, node.Body))
+	buf.WriteString("\nend\n")
+	return buf.String()
+}
+
+// translateSelectStmt translates a select outside a goroutine, where the
+// first ready case is taken and blocking means deadlock. Without a default,
+// a timer case such as <-time.After(d) waits for d when no other case is
+// ready, as nothing else could make one ready meanwhile.
+func translateSelectStmt(info *types.Info, node *ast.SelectStmt) string {
+	var buf strings.Builder
+	var dflt, timer *ast.CommClause
+	keyword := "if"
+	for _, stmt := range node.Body.List {
+		clause := stmt.(*ast.CommClause)
+		var body []string
+		switch comm := clause.Comm.(type) {
+		case nil:
+			dflt = clause
+			continue
+		case *ast.SendStmt:
+			fmt.Fprintf(&buf, "%s %s %s\n", keyword, useHelper("__ready_send"), translateExpr(info, comm.Chan))
+			body = append(body, translateSendStmt(info, comm))
+		default:
+			recv := commRecv(comm)
+			if timerInterval(info, recv.X) != nil {
+				if timer == nil {
+					timer = clause
+				}
+				continue
+			}
+			fmt.Fprintf(&buf, "%s %s %s\n", keyword, useHelper("__ready_recv"), translateExpr(info, recv.X))
+			if assign, ok := comm.(*ast.AssignStmt); ok {
+				body = append(body, translateLhs(info, assign.Lhs[0])+" "+assign.Tok.String()+" "+translateRecv(info, recv))
+			} else {
+				body = append(body, translateRecv(info, recv))
+			}
+		}
+		for _, stmt := range clause.Body {
+			body = append(body, translateStmt(info, stmt))
+		}
+		buf.WriteString(i(strings.Join(body, "\n")))
+		buf.WriteString("\n")
+		keyword = "else if"
+	}
+	var rest []string
+	switch {
+	case dflt == nil && timer != nil:
+		recv := commRecv(timer.Comm)
+		if assign, ok := timer.Comm.(*ast.AssignStmt); ok {
+			value, _ := translateTimerRecv(info, recv)
+			rest = append(rest, translateLhs(info, assign.Lhs[0])+" "+assign.Tok.String()+" "+value)
+		} else {
+			wait, _ := translateTimerWait(info, recv)
+			rest = append(rest, wait)
+		}
+		for _, stmt := range timer.Body {
+			rest = append(rest, translateStmt(info, stmt))
+		}
+	case dflt == nil:
+		rest = append(rest, useHelper("__deadlock"))
+	default:
+		for _, stmt := range dflt.Body {
+			rest = append(rest, translateStmt(info, stmt))
+		}
+	}
+	if keyword == "if" {
+		return strings.Join(rest, "\n")
+	}
+	buf.WriteString("else\n")
+	buf.WriteString(i(strings.Join(rest, "\n")))
+	buf.WriteString("\nend\n")
+	return buf.String()
+}
+
+func init() {
+	addHelpers(map[string]helper{
+		// __wake is the earliest time a sleeping task wakes up, or 0.
+		"__sched": {src: `
+__tasks:[]{}any
+__progress := false
+__wake := 0`},
+		"__panic": {src: `
+func __panic msg:string
+    print ("panic: " + msg)
+    exit 2
+end`},
+		"__deadlock": {src: `
+func __deadlock
+    print "fatal error: all goroutines are asleep - deadlock!"
+    exit 2
+end`},
+		"__task": {src: `
+func __task:{}any fn:string
+    t:{}any
+    t.fn = fn
+    t.pc = 0
+    t.done = false
+    return t
+end`},
+		"__go": {deps: []string{"__sched"}, src: `
+func __go t:{}any
+    __tasks = __tasks + [t]
+    __progress = true
+end`},
+		"__goto": {deps: []string{"__sched"}, src: `
+func __goto t:{}any pc:num
+    t.pc = pc
+    __progress = true
+end`},
+		"__done": {deps: []string{"__sched"}, src: `
+func __done t:{}any
+    t.done = true
+    __progress = true
+end`},
+		"__run": {deps: []string{"__sched", "__deadlock"}, src: `
+func __run
+    while true
+        __progress = false
+        __wake = 0
+        i := 0
+        while i < (len __tasks)
+            t := __tasks[i]
+            if !(t.done.(bool))
+                __dispatch t
+            end
+            if __tasks[0].done.(bool)
+                return
+            end
+            i = i + 1
+        end
+        if !__progress
+            if __wake == 0
+                __deadlock
+            end
+            sleep (max 0 (__wake - (now)))
+        end
+    end
+end`},
+		// __timer_ready reports whether the deadline of a timer case in a
+		// select has passed, leaving the time received in t.__val.
+		"__timer_ready": {deps: []string{"__sched"}, src: `
+func __timer_ready:bool t:{}any deadline:num
+    if (now) < deadline
+        if __wake == 0 or deadline < __wake
+            __wake = deadline
+        end
+        return false
+    end
+    t.__val = (now)
+    t.__ok = true
+    return true
+end`},
+		// __sleep reports whether the time a task sleeps for has passed,
+		// starting the sleep on the first call.
+		"__sleep": {deps: []string{"__sched"}, src: `
+func __sleep:bool t:{}any d:num
+    if !(has t "__until")
+        t.__until = (now) + d
+    end
+    until := t.__until.(num)
+    if (now) >= until
+        del t "__until"
+        return true
+    end
+    if __wake == 0 or until < __wake
+        __wake = until
+    end
+    return false
+end`},
+		"__chan": {src: `
+func __chan:{}any size:num
+    ch:{}any
+    ch.buf = []
+    ch.cap = size
+    ch.closed = false
+    ch.sent = 0
+    ch.recvd = 0
+    return ch
+end`},
+		"__close": {deps: []string{"__panic", "__sched"}, src: `
+func __close ch:{}any
+    if ch.closed.(bool)
+        __panic "close of closed channel"
+    end
+    ch.closed = true
+    __progress = true
+end`},
+		"__ready_recv": {src: `
+func __ready_recv:bool ch:{}any
+    return (len ch.buf.([]any)) > 0 or ch.closed.(bool)
+end`},
+		"__ready_send": {src: `
+func __ready_send:bool ch:{}any
+    return (len ch.buf.([]any)) < (max 1 ch.cap.(num))
+end`},
+		"__take": {deps: []string{"__sched"}, src: `
+func __take t:{}any ch:{}any zero:any
+    buf := ch.buf.([]any)
+    if (len buf) == 0
+        t.__val = zero
+        t.__ok = false
+        return
+    end
+    t.__val = buf[0]
+    t.__ok = true
+    ch.buf = buf[1:]
+    ch.recvd = ch.recvd.(num) + 1
+    __progress = true
+end`},
+		// __send hands v over to ch and reports whether the task may
+		// continue. A send on an unbuffered channel takes a ticket and
+		// completes once the receiver has taken the value.
+		"__send": {deps: []string{"__panic", "__sched"}, src: `
+func __send:bool t:{}any ch:{}any v:any
+    if has t "__ticket"
+        if ch.recvd.(num) > t.__ticket.(num)
+            del t "__ticket"
+            return true
+        end
+        return false
+    end
+    if ch.closed.(bool)
+        __panic "send on closed channel"
+    end
+    buf := ch.buf.([]any)
+    if (len buf) >= (max 1 ch.cap.(num))
+        return false
+    end
+    ch.buf = buf + [v]
+    __progress = true
+    if ch.cap.(num) > 0
+        ch.sent = ch.sent.(num) + 1
+        return true
+    end
+    t.__ticket = ch.sent
+    ch.sent = ch.sent.(num) + 1
+    return false
+end`},
+		"__send_now": {deps: []string{"__panic", "__deadlock"}, src: `
+func __send_now ch:{}any v:any
+    if ch.closed.(bool)
+        __panic "send on closed channel"
+    end
+    if (len ch.buf.([]any)) >= ch.cap.(num)
+        __deadlock
+    end
+    ch.buf = ch.buf.([]any) + [v]
+    ch.sent = ch.sent.(num) + 1
+end`},
+		"__recv_now": {deps: []string{"__deadlock"}, src: `
+func __recv_now:any ch:{}any zero:any
+    buf := ch.buf.([]any)
+    if (len buf) == 0
+        if !(ch.closed.(bool))
+            __deadlock
+        end
+        return zero
+    end
+    ch.buf = buf[1:]
+    ch.recvd = ch.recvd.(num) + 1
+    return buf[0]
+end`},
+		"__recv_ok": {deps: []string{"__recv_now"}, src: `
+func __recv_ok:[]any ch:{}any zero:any
+    ok := (len ch.buf.([]any)) > 0
+    return [(__recv_now ch zero) ok]
+end`},
+		"__drain": {deps: []string{"__deadlock"}, src: `
+func __drain:[]any ch:{}any
+    if !(ch.closed.(bool))
+        __deadlock
+    end
+    buf := ch.buf.([]any)
+    ch.buf = []
+    ch.recvd = ch.recvd.(num) + (len buf)
+    return buf
+end`},
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// helper is a snippet of Evy source emitted once, ahead of the translated
+// program, for Go constructs that have no direct Evy equivalent.
+type helper struct {
+	deps []string
+	src  string
+}
+
+// helperDefs holds every known helper keyed by the Evy name it defines.
+var helperDefs = map[string]helper{}
+
+// usedHelpers lists the helpers required by the file being translated, in
+// the order they are emitted. Dependencies always precede their users.
+var (
+	usedHelpers []string
+	helperUsed  map[string]bool
+)
+
+// tempCount numbers the temporaries introduced while translating a file.
+var tempCount int
+
+func addHelpers(defs map[string]helper) {
+	for name, h := range defs {
+		helperDefs[name] = h
+	}
+}
+
+func resetHelpers() {
+	usedHelpers = nil
+	helperUsed = map[string]bool{}
+	tempCount = 0
+}
+
+// useHelper marks the named helper, and everything it depends on, as needed
+// by the current file and returns the name for use in generated code.
+func useHelper(name string) string {
+	if helperUsed[name] {
+		return name
+	}
+	h, ok := helperDefs[name]
+	if !ok {
+		panic("unknown helper " + name)
+	}
+	helperUsed[name] = true
+	for _, dep := range h.deps {
+		useHelper(dep)
+	}
+	usedHelpers = append(usedHelpers, name)
+	return name
+}
+
+func helperSource() string {
+	var buf strings.Builder
+	for _, name := range usedHelpers {
+		buf.WriteString(n(helperDefs[name].src))
+	}
+	return buf.String()
+}
+
+// newTemp returns a fresh identifier for a generated temporary.
+func newTemp(prefix string) string {
+	tempCount++
+	return fmt.Sprintf("__%s%d", prefix, tempCount)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// Input is read from os.Stdin a line at a time with Evy's read. Words
+// fmt.Scan reads past the values it needs are kept for the next call, as
+// Go leaves them unread. Evy's read cannot tell the end of input from an
+// empty line, so an empty line ends fmt.Scan and the lines of a scanner.
+//
+// A scan assigns to the variables its arguments point to, so it is
+// translated as statements: the helper __scan returns the number of
+// values scanned, the error and the values, and the targets are assigned
+// from it. See scanTuple.
+
+// scanTuple translates a call to one of the Scan functions of package fmt
+// into the statements scanning and assigning its targets, and returns the
+// expressions reading the call's results.
+func scanTuple(info *types.Info, call *ast.CallExpr) (prefix, values []string, ok bool) {
+	sel, isSel := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !isSel {
+		return nil, nil, false
+	}
+	fn, isFunc := info.Uses[sel.Sel].(*types.Func)
+	if !isFunc || fn.Pkg() == nil || fn.Pkg().Path() != "fmt" || !strings.Contains(strings.ToLower(fn.Name()), "scan") {
+		return nil, nil, false
+	}
+	name, args := fn.Name(), call.Args
+	var words string
+	switch name[0] {
+	case 'F':
+		if !isPkgVar(info, args[0], "os", "Stdin") && !isStdinReader(info, args[0]) {
+			diagnose(call.Pos(), "fmt.%s is only supported reading os.Stdin", fn.Name())
+			return nil, nil, false
+		}
+		args, name = args[1:], name[1:]
+	case 'S':
+		if name[1] == 's' {
+			words = "(" + evyCall(useHelper("__fields"), translateOperand(info, args[0])) + ")"
+			args, name = args[1:], name[1:]
+		}
+	}
+	if name == "Scanf" {
+		if !scanFormat(info, args[0], len(args)-1) {
+			return nil, nil, false
+		}
+		args, name = args[1:], "Scanln"
+	}
+	line := name == "Scanln"
+	if words == "" {
+		words = "(" + useHelper("__scan_line") + ")"
+		if !line {
+			words = fmt.Sprintf("(%s %d)", useHelper("__scan_words"), len(args))
+			diagnoseEmptyLine(call.Pos(), "fmt."+fn.Name())
+		}
+	}
+	var kinds, targets []string
+	for _, arg := range args {
+		ptr, isAddr := ast.Unparen(arg).(*ast.UnaryExpr)
+		kind := scanKind(info.TypeOf(arg))
+		if !isAddr || ptr.Op != token.AND || kind == "" {
+			diagnose(arg.Pos(), "fmt.%s is only supported with &v arguments of basic type", fn.Name())
+			return nil, nil, false
+		}
+		kinds = append(kinds, fmt.Sprintf("%q", kind))
+		targets = append(targets, translateLhs(info, ptr.X)+" = %s.("+evyType(info.TypeOf(ptr.X)).String()+")")
+	}
+	tmp := newTemp("scan")
+	prefix = []string{fmt.Sprintf("%s := %s %s [%s] %t", tmp, useHelper("__scan"), words, strings.Join(kinds, " "), line)}
+	for n, target := range targets {
+		value := fmt.Sprintf("%s[%d]", tmp, n+2)
+		prefix = append(prefix, fmt.Sprintf("if (len %s) > %d\n%s\nend", tmp, n+2, i(fmt.Sprintf(target, value))))
+	}
+	return prefix, []string{tmp + "[0].(num)", tmp + "[1]"}, true
+}
+
+// scanKind returns how __scan parses a value for a target of type *t.
+func scanKind(t types.Type) string {
+	ptr, ok := t.(*types.Pointer)
+	if !ok {
+		return ""
+	}
+	switch elem := ptr.Elem(); {
+	case isString(elem):
+		return "string"
+	case isInteger(elem):
+		return "int"
+	case isNumeric(elem):
+		return "num"
+	case types.Identical(elem.Underlying(), types.Typ[types.Bool]):
+		return "bool"
+	}
+	return ""
+}
+
+// scanFormat reports whether the format of fmt.Scanf consists of n
+// verbs separated by spaces, which is all that is supported.
+func scanFormat(info *types.Info, format ast.Expr, n int) bool {
+	val := info.Types[format].Value
+	if val == nil || val.Kind() != constant.String {
+		diagnose(format.Pos(), "fmt.Scanf is only supported with a constant format")
+		return false
+	}
+	verbs := strings.Fields(constant.StringVal(val))
+	for _, verb := range verbs {
+		if len(verb) != 2 || verb[0] != '%' || !strings.ContainsRune("vdsfgtq", rune(verb[1])) {
+			diagnose(format.Pos(), "fmt.Scanf is only supported with formats of verbs separated by spaces")
+			return false
+		}
+	}
+	if len(verbs) != n {
+		diagnose(format.Pos(), "fmt.Scanf format has %d verbs for %d arguments", len(verbs), n)
+		return false
+	}
+	return true
+}
+
+// translateBufioCall translates reading os.Stdin with a bufio.Scanner or
+// bufio.Reader. A scanner is a map holding the current token and whether
+// it splits words.
+func translateBufioCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	var x string // the scanner or reader
+	if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok && info.Selections[sel] != nil {
+		x = translateOperand(info, sel.X)
+	}
+	switch name {
+	case "NewScanner", "NewReader":
+		if !isPkgVar(info, call.Args[0], "os", "Stdin") {
+			diagnose(call.Pos(), "bufio.%s is only supported reading os.Stdin", name)
+			return "", false
+		}
+		if name == "NewReader" {
+			return "{}", true
+		}
+		return `{text: "" words: false}`, true
+	case "Split":
+		switch {
+		case isPkgFunc(info, call.Args[0], "bufio", "ScanWords"):
+			return x + ".words = true", true
+		case isPkgFunc(info, call.Args[0], "bufio", "ScanLines"):
+			return x + ".words = false", true
+		}
+		diagnose(call.Pos(), "bufio.Scanner is only supported splitting lines or words")
+		return "", false
+	case "Scan":
+		diagnoseEmptyLine(call.Pos(), "bufio.Scanner.Scan")
+		return evyCall(useHelper("__scanner_scan"), x), true
+	case "Text":
+		return x + ".text.(string)", true
+	case "Err":
+		return "false", true
+	case "ReadString":
+		if val := info.Types[call.Args[0]].Value; val == nil || constant.Compare(val, token.NEQ, constant.MakeInt64('\n')) {
+			diagnose(call.Pos(), "bufio.Reader.ReadString is only supported reading lines")
+		}
+		diagnoseEmptyLine(call.Pos(), "bufio.Reader.ReadString")
+		return "(" + useHelper("__read_string") + ")", true
+	}
+	diagnose(call.Pos(), "bufio.%s is not supported", name)
+	return "", false
+}
+
+// diagnoseEmptyLine reports that fn treats an empty input line as the end
+// of input; see the comment at the top of the file.
+func diagnoseEmptyLine(pos token.Pos, fn string) {
+	diagnose(pos, "%s stops at an empty input line, which Evy's read cannot tell from the end of input", fn)
+}
+
+// isStdinReader reports whether expr is a bufio.Reader, which can only
+// be reading os.Stdin.
+func isStdinReader(info *types.Info, expr ast.Expr) bool {
+	named, ok := types.Unalias(deref(info.TypeOf(expr))).(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "bufio" && named.Obj().Name() == "Reader"
+}
+
+// isPkgFunc reports whether expr denotes the named package-level function.
+func isPkgFunc(info *types.Info, expr ast.Expr, path, name string) bool {
+	sel, ok := ast.Unparen(expr).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	fn, ok := info.Uses[sel.Sel].(*types.Func)
+	return ok && fn.Pkg() != nil && fn.Pkg().Path() == path && fn.Name() == name
+}
+
+func init() {
+	stdlibCalls["bufio"] = translateBufioCall
+	stdlibCalls["bufio.Scanner"] = translateBufioCall
+	stdlibCalls["bufio.Reader"] = translateBufioCall
+	addHelpers(map[string]helper{
+		"__scan_pending": {src: "\n__scan_pending:[]string"},
+		"__scan_words": {deps: []string{"__scan_pending", "__fields"}, src: `
+func __scan_words:[]string n:num
+    while (len __scan_pending) < n
+        line := read
+        if line == ""
+            break
+        end
+        __scan_pending = __scan_pending + (__fields line)
+    end
+    m := min n (len __scan_pending)
+    words := __scan_pending[:m]
+    __scan_pending = __scan_pending[m:]
+    return words
+end`},
+		// __scan_line returns the words left on the current line, or
+		// those of the next line.
+		"__scan_line": {deps: []string{"__scan_pending", "__fields"}, src: `
+func __scan_line:[]string
+    if (len __scan_pending) > 0
+        words := __scan_pending
+        __scan_pending = []
+        return words
+    end
+    return __fields (read)
+end`},
+		"__scan": {src: `
+func __scan:[]any words:[]string kinds:[]string line:bool
+    r:[]any
+    r = [0 false]
+    for i := range (len kinds)
+        if i >= (len words)
+            if line
+                r[1] = "unexpected newline"
+            else
+                r[1] = "EOF"
+            end
+            return r
+        end
+        w := words[i]
+        if kinds[i] == "string"
+            r = r + [w]
+        else if kinds[i] == "bool"
+            b := str2bool w
+            if err
+                r[1] = "syntax error scanning boolean"
+                return r
+            end
+            r = r + [b]
+        else
+            n := str2num w
+            if err
+                r[1] = sprintf "strconv.ParseFloat: parsing %q: invalid syntax" w
+                if kinds[i] == "int"
+                    r[1] = "expected integer"
+                end
+                return r
+            end
+            if kinds[i] == "int" and n != (floor n)
+                r[1] = "expected integer"
+                return r
+            end
+            r = r + [n]
+        end
+        r[0] = i + 1
+    end
+    if line and (len words) > (len kinds)
+        r[1] = "expected newline"
+    end
+    return r
+end`},
+		"__scanner_scan": {deps: []string{"__scan_words"}, src: `
+func __scanner_scan:bool s:{}any
+    if s.words.(bool)
+        words := __scan_words 1
+        if (len words) == 0
+            return false
+        end
+        s.text = words[0]
+        return true
+    end
+    line := read
+    if line == ""
+        return false
+    end
+    s.text = line
+    return true
+end`},
+		"__read_string": {src: `
+func __read_string:[]any
+    line := read
+    if line == ""
+        return ["" "EOF"]
+    end
+    return [(line + "\n") false]
+end`},
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// Constants of package math are folded to literals like any other constant
+// expression; see foldConst.
+
+// mathBuiltins are the math functions with an Evy builtin of the same
+// arguments.
+var mathBuiltins = map[string]string{
+	"Sqrt":  "sqrt",
+	"Pow":   "pow",
+	"Floor": "floor",
+	"Ceil":  "ceil",
+	"Round": "round",
+	"Max":   "max",
+	"Min":   "min",
+	"Log":   "log",
+	"Sin":   "sin",
+	"Cos":   "cos",
+	"Atan2": "atan2",
+}
+
+// mathHelpers are the math functions implemented by a generated helper of
+// the same arguments.
+var mathHelpers = map[string]string{
+	"Abs":   "__abs",
+	"Mod":   "__fmod",
+	"Trunc": "__trunc",
+	"Hypot": "__hypot",
+	"Inf":   "__inf",
+	"IsInf": "__isinf",
+	"NaN":   "__nan",
+}
+
+// translateMathCall translates a call to a function of package math.
+func translateMathCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	args := operands(info, call.Args)
+	if builtin, ok := mathBuiltins[name]; ok {
+		return evyCall(builtin, args...), true
+	}
+	if helper, ok := mathHelpers[name]; ok {
+		return evyCall(useHelper(helper), args...), true
+	}
+	switch name {
+	case "IsNaN":
+		return "(" + args[0] + " != " + args[0] + ")", true
+	case "Log2":
+		return "((log " + args[0] + ") / (log 2))", true
+	case "Log10":
+		return "((log " + args[0] + ") / (log 10))", true
+	case "Exp":
+		return evyCall("pow", "2.718281828459045", args[0]), true
+	case "Tan":
+		return "((sin " + args[0] + ") / (cos " + args[0] + "))", true
+	case "Atan":
+		return evyCall("atan2", args[0], "1"), true
+	}
+	diagnose(call.Pos(), "math.%s is not supported", name)
+	return "", false
+}
+
+func init() {
+	stdlibCalls["math"] = translateMathCall
+	addHelpers(map[string]helper{
+		"__abs": {src: `
+func __abs:num x:num
+    if x < 0
+        return -x
+    end
+    return x
+end`},
+		"__fmod": {deps: []string{"__trunc"}, src: `
+func __fmod:num x:num y:num
+    return x - y * (__trunc (x / y))
+end`},
+		"__hypot": {src: `
+func __hypot:num x:num y:num
+    return sqrt (x * x + y * y)
+end`},
+		"__inf": {src: `
+func __inf:num sign:num
+    inf := pow 10 400
+    if sign < 0
+        return -inf
+    end
+    return inf
+end`},
+		"__isinf": {deps: []string{"__inf"}, src: `
+func __isinf:bool x:num sign:num
+    if sign >= 0 and x == (__inf 1)
+        return true
+    end
+    return sign <= 0 and x == (__inf (-1))
+end`},
+		"__nan": {deps: []string{"__inf"}, src: `
+func __nan:num
+    return (__inf 1) - (__inf 1)
+end`},
+	})
+}
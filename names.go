@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"evylang.dev/evy/pkg/evaluator"
+)
+
+// evyKeywords are the reserved words of Evy, including its type names.
+var evyKeywords = map[string]bool{
+	"and": true, "any": true, "bool": true, "break": true, "else": true,
+	"end": true, "false": true, "for": true, "func": true, "if": true,
+	"num": true, "on": true, "or": true, "range": true, "return": true,
+	"string": true, "true": true, "while": true,
+}
+
+// evyBuiltins are the functions Evy predeclares, and evyGlobals its
+// predeclared variables, such as err, which its conversions set.
+var (
+	evyBuiltins = evaluator.BuiltinDecls().Funcs
+	evyGlobals  = evaluator.BuiltinDecls().Globals
+)
+
+// isReserved reports whether a Go identifier cannot name a user variable or
+// function in Evy. Names starting with two underscores are kept for
+// generated code.
+func isReserved(name string) bool {
+	if _, ok := evyBuiltins[name]; ok {
+		return true
+	}
+	if _, ok := evyGlobals[name]; ok {
+		return true
+	}
+	return evyKeywords[name] || strings.HasPrefix(name, "__")
+}
+
+// nameStyle is the naming profile of translated identifiers: "keep" leaves
+// Go names as they are and "snake" converts them to snake_case.
+var nameStyle = "keep"
+
+// snakeCase converts a camelCase or PascalCase name to snake_case, keeping
+// acronyms together: "calculateArea" becomes "calculate_area" and
+// "parseHTTPHeader" becomes "parse_http_header".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var buf strings.Builder
+	for n, r := range runes {
+		if unicode.IsUpper(r) && n > 0 && runes[n-1] != '_' {
+			prev := runes[n-1]
+			nextLower := n+1 < len(runes) && unicode.IsLower(runes[n+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				buf.WriteRune('_')
+			}
+		}
+		buf.WriteRune(unicode.ToLower(r))
+	}
+	return buf.String()
+}
+
+// evyIdent returns the Evy spelling of a Go identifier in the naming
+// style: non-ASCII letters are spelled out by code point and reserved
+// names get a trailing underscore, so "len" becomes "len_" and "π" becomes
+// "u03c0".
+func evyIdent(name string) string {
+	if nameStyle == "snake" {
+		name = snakeCase(name)
+	}
+	var buf strings.Builder
+	for _, r := range name {
+		if r < utf8.RuneSelf {
+			buf.WriteRune(r)
+		} else {
+			fmt.Fprintf(&buf, "u%04x", r)
+		}
+	}
+	s := buf.String()
+	if strings.HasPrefix(s, "__") {
+		s = "u" + s
+	}
+	if isReserved(s) {
+		s += "_"
+	}
+	return s
+}
+
+// renameGlobals renames the package-level functions, variables and
+// constants whose names Evy cannot use or that change with the naming
+// style, and the fields of the file's structs. Methods are named after
+// their receiver type; see funcName.
+func renameGlobals(info *types.Info, file *ast.File) {
+	renames = map[types.Object]string{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		if s, ok := node.(*ast.StructType); ok {
+			var fields []*ast.Ident
+			for _, field := range s.Fields.List {
+				fields = append(fields, field.Names...)
+			}
+			renameIdents(info, fields)
+		}
+		return true
+	})
+	var ids []*ast.Ident
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				ids = append(ids, d.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if valueSpec, ok := spec.(*ast.ValueSpec); ok {
+					ids = append(ids, valueSpec.Names...)
+				}
+			}
+		}
+	}
+	renameIdents(info, ids)
+}
+
+// renameIdents renames identifiers declared in one namespace to their Evy
+// spelling, numbering the names that then collide.
+func renameIdents(info *types.Info, ids []*ast.Ident) {
+	taken := map[string]bool{}
+	for _, id := range ids {
+		taken[id.Name] = true
+	}
+	for _, id := range ids {
+		obj := info.Defs[id]
+		name := evyIdent(id.Name)
+		if obj == nil || id.Name == "_" || name == id.Name {
+			continue
+		}
+		base := name
+		for n := 1; taken[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		taken[name] = true
+		renames[obj] = name
+	}
+}
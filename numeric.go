@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+)
+
+// Evy has a single num type, a float64. Integer arithmetic stays exact as
+// long as nothing introduces a fraction, so integer division, remainder and
+// float to integer conversions are lowered to helpers that truncate the way
+// Go does, and every integer-typed value remains integral.
+//
+// With -wrap, arithmetic on sized integer types is also reduced modulo 2^N
+// and float32 results are rounded to float32 precision. Signed 64-bit
+// integers are not wrapped, as they lose precision long before they
+// overflow; operations that may leave the exact range are diagnosed instead.
+
+// wrapInts enables wraparound and float32 emulation.
+var wrapInts bool
+
+func isInteger(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0
+}
+
+// intSize returns the width of a sized integer type and whether it is
+// signed. int, uint and uintptr are 64 bits wide.
+func intSize(t types.Type) (bits int, signed bool) {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return 0, false
+	}
+	switch basic.Kind() {
+	case types.Int8:
+		return 8, true
+	case types.Int16:
+		return 16, true
+	case types.Int32:
+		return 32, true
+	case types.Int, types.Int64:
+		return 64, true
+	case types.Uint8:
+		return 8, false
+	case types.Uint16:
+		return 16, false
+	case types.Uint32:
+		return 32, false
+	case types.Uint, types.Uint64, types.Uintptr:
+		return 64, false
+	}
+	return 0, false
+}
+
+func isWide32(t types.Type) bool {
+	bits, _ := intSize(t)
+	return bits == 32
+}
+
+// translateArith returns the Evy expression applying op to the translated
+// operands x and y, where t is the type of the operation.
+func translateArith(pos token.Pos, op token.Token, t types.Type, x, y string) string {
+	if isDuration(t) {
+		if s, ok := translateDurationArith(op, x, y); ok {
+			return s
+		}
+	}
+	var s string
+	switch {
+	case isInteger(t) && op == token.QUO:
+		s = useHelper("__idiv") + " " + x + " " + y
+	case isInteger(t) && op == token.REM:
+		return useHelper("__imod") + " " + x + " " + y
+	case wrapInts && op == token.MUL && isWide32(t):
+		// The product of two 32-bit integers can exceed 2^53.
+		bits, signed := intSize(t)
+		return fmt.Sprintf("%s %s %s %d %t", useHelper("__wrapmul"), x, y, bits, signed)
+	case bitwiseHelpers[op] != "":
+		s = useHelper(bitwiseHelpers[op]) + " " + x + " " + y
+	default:
+		s = x + " " + translateOperator(op) + " " + y
+	}
+	switch op {
+	case token.ADD, token.SUB, token.MUL, token.QUO, token.SHL:
+		return wrapValue(t, s)
+	}
+	return s
+}
+
+// diagnoseInexact reports integer arithmetic x op y of type t whose result
+// may exceed 2^53, beyond which an Evy num no longer holds every integer. y
+// is nil for ++ and --. int is the number most programs count with, so its
+// values are taken to stay in range unless two variables are multiplied or
+// a shift count is variable. Other sized operands are bounded by their
+// width, or by their value if constant, and with -wrap products and shifts
+// of types narrower than 64 bits stay exact.
+func diagnoseInexact(info *types.Info, pos token.Pos, op token.Token, t types.Type, x, y ast.Expr) {
+	bits, _ := intSize(t)
+	if bits == 0 || isDuration(t) || (isConstant(info, x) && isConstant(info, y)) {
+		return
+	}
+	const exact = 1 << 53
+	var inexact bool
+	switch {
+	case isPlatformInt(t):
+		inexact = op == token.MUL && !isConstant(info, x) && !isConstant(info, y) ||
+			op == token.SHL && !isConstant(info, y)
+	case op == token.ADD || op == token.SUB:
+		inexact = bits == 64
+	case op == token.MUL:
+		inexact = bits == 64 || !wrapInts && intBound(info, x, bits)*intBound(info, y, bits) > exact
+	case op == token.SHL:
+		inexact = bits == 64 || !wrapInts && intBound(info, x, bits)*math.Pow(2, intBound(info, y, 64)) > exact
+	}
+	if inexact {
+		diagnose(pos, "%s %s may exceed the integers an Evy num represents exactly", t, op)
+	}
+}
+
+func isConstant(info *types.Info, x ast.Expr) bool {
+	return x == nil || info.Types[x].Value != nil
+}
+
+// intBound returns the largest magnitude the operand x of a bits wide
+// integer operation can have.
+func intBound(info *types.Info, x ast.Expr, bits int) float64 {
+	if x == nil {
+		return 1
+	}
+	if val := info.Types[x].Value; val != nil {
+		f, _ := constant.Float64Val(constant.ToFloat(val))
+		return math.Abs(f)
+	}
+	return math.Pow(2, float64(bits))
+}
+
+func isFloat32(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Kind() == types.Float32
+}
+
+func isPlatformInt(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && (basic.Kind() == types.Int || basic.Kind() == types.Uint || basic.Kind() == types.Uintptr)
+}
+
+// wrapValue reduces the translated value s to the range of t with -wrap.
+func wrapValue(t types.Type, s string) string {
+	if !wrapInts {
+		return s
+	}
+	if isFloat32(t) {
+		return useHelper("__f32") + " (" + s + ")"
+	}
+	bits, signed := intSize(t)
+	if bits == 0 || (bits == 64 && signed) {
+		return s
+	}
+	return fmt.Sprintf("%s (%s) %d %t", useHelper("__wrap"), s, bits, signed)
+}
+
+// translateConversion translates a conversion such as int(f) or float64(i).
+// Runes and bytes are nums, so converting them to and from strings goes
+// through __chr and __ord, which know the ASCII characters only.
+func translateConversion(info *types.Info, call *ast.CallExpr) (string, bool) {
+	if tv, ok := info.Types[call.Fun]; !ok || !tv.IsType() || len(call.Args) != 1 {
+		return "", false
+	}
+	to, from := info.TypeOf(call), info.TypeOf(call.Args[0])
+	_, toSlice := to.Underlying().(*types.Slice)
+	_, fromSlice := from.Underlying().(*types.Slice)
+	switch {
+	case isString(to) && isInteger(from):
+		return evyCall(useHelper("__chr"), translateOperand(info, call.Args[0])), true
+	case isString(to) && fromSlice:
+		return evyCall(useHelper("__runes_string"), translateOperand(info, call.Args[0])), true
+	case toSlice && isString(from):
+		if val := info.Types[call.Args[0]].Value; val != nil && !isASCII(constant.StringVal(val)) {
+			diagnose(call.Pos(), "converting non-ASCII strings to %s is not supported", to)
+		}
+		return evyCall(useHelper("__runes"), translateOperand(info, call.Args[0])), true
+	case isDuration(to) != isDuration(from) && isNumeric(to) && isNumeric(from):
+		return translateDurationConversion(info, to, from, call.Args[0]), true
+	case isInteger(to) && !isInteger(from) && isNumeric(from):
+		return wrapValue(to, useHelper("__trunc")+" "+translateOperand(info, call.Args[0])), true
+	case isNumeric(to) && isNumeric(from) && !types.Identical(to.Underlying(), from.Underlying()):
+		return wrapValue(to, translateExpr(info, call.Args[0])), true
+	}
+	return translateExpr(info, call.Args[0]), true
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > 126 {
+			return false
+		}
+	}
+	return true
+}
+
+func isNumeric(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsNumeric != 0
+}
+
+func init() {
+	addHelpers(map[string]helper{
+		"__ascii": {src: "\n__ascii := \" !\\\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\\\]^_`abcdefghijklmnopqrstuvwxyz{|}~\""},
+		"__chr": {deps: []string{"__ascii", "__panic"}, src: `
+func __chr:string n:num
+    if n == 9
+        return "\t"
+    else if n == 10
+        return "\n"
+    else if n == 13
+        return "\r"
+    else if n < 32 or n > 126
+        __panic (sprintf "rune %v is not supported, only ASCII" n)
+    end
+    return __ascii[n - 32]
+end`},
+		"__ord": {deps: []string{"__ascii", "__panic"}, src: `
+func __ord:num c:string
+    if c == "\t"
+        return 9
+    else if c == "\n"
+        return 10
+    else if c == "\r"
+        return 13
+    end
+    n := index __ascii c
+    if n < 0
+        __panic (sprintf "character %q is not supported, only ASCII" c)
+    end
+    return n + 32
+end`},
+		"__runes": {deps: []string{"__ord"}, src: `
+func __runes:[]num s:string
+    r:[]num
+    for c := range s
+        r = r + [(__ord c)]
+    end
+    return r
+end`},
+		"__runes_string": {deps: []string{"__chr"}, src: `
+func __runes_string:string r:[]num
+    s := ""
+    for n := range r
+        s = s + (__chr n)
+    end
+    return s
+end`},
+		"__trunc": {src: `
+func __trunc:num x:num
+    if x < 0
+        return ceil x
+    end
+    return floor x
+end`},
+		"__idiv": {deps: []string{"__panic", "__trunc"}, src: `
+func __idiv:num a:num b:num
+    if b == 0
+        __panic "runtime error: integer divide by zero"
+    end
+    return __trunc (a / b)
+end`},
+		"__imod": {deps: []string{"__idiv"}, src: `
+func __imod:num a:num b:num
+    return a - b * (__idiv a b)
+end`},
+		"__wrap": {src: `
+func __wrap:num x:num bits:num signed:bool
+    m := pow 2 bits
+    x = x - m * (floor (x / m))
+    if signed and x >= m / 2
+        x = x - m
+    end
+    return x
+end`},
+		"__wrapmul": {deps: []string{"__wrap"}, src: `
+func __wrapmul:num a:num b:num bits:num signed:bool
+    a = __wrap a bits false
+    b = __wrap b bits false
+    hi := floor (b / 65536)
+    lo := b - hi * 65536
+    return __wrap ((__wrap (a * hi) bits false) * 65536 + a * lo) bits signed
+end`},
+		// __f32 rounds x to the nearest float32, ties to even. Subnormals
+		// and overflow to infinity are not emulated.
+		"__f32": {src: `
+func __f32:num x:num
+    if x == 0
+        return x
+    end
+    a := x
+    if a < 0
+        a = -a
+    end
+    scale := 1
+    while a * scale >= 16777216
+        scale = scale / 2
+    end
+    while a * scale < 8388608
+        scale = scale * 2
+    end
+    m := a * scale
+    f := floor m
+    d := m - f
+    if d > 0.5 or (d == 0.5 and f % 2 == 1)
+        f = f + 1
+    end
+    if x < 0
+        return -f / scale
+    end
+    return f / scale
+end`},
+		"__f32_string": {deps: []string{"__f32"}, src: `
+func __f32_string:string x:num
+    a := __f32 x
+    if a < 0
+        a = -a
+    end
+    if a == 0
+        return sprint x
+    end
+    e := floor ((log a) / (log 10))
+    while (pow 10 e) > a
+        e = e - 1
+    end
+    while (pow 10 (e + 1)) <= a
+        e = e + 1
+    end
+    for p := range 1 10
+        r := 0
+        if p - 1 - e >= 0
+            scale := pow 10 (p - 1 - e)
+            r = (round (a * scale)) / scale
+        else
+            scale := pow 10 (e - p + 1)
+            r = (round (a / scale)) * scale
+        end
+        if (__f32 r) == a
+            if x < 0
+                return sprint (-r)
+            end
+            return sprint r
+        end
+    end
+    return sprint x
+end`},
+	})
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Constant format strings are parsed at translation time. Evy's printf is
+// given a format using only %v and %q; widths, precisions, flags and the
+// verbs Evy lacks are applied to the arguments by generated helpers, and
+// argument indexes are resolved by reordering the arguments.
+
+// formatArgs translates the format string and arguments of a Printf-style
+// call to the arguments of Evy's printf or sprintf.
+func formatArgs(info *types.Info, pos token.Pos, args []ast.Expr) []string {
+	value := info.Types[args[0]].Value
+	if value == nil || value.Kind() != constant.String {
+		diagnose(pos, "format string is not constant and is passed to Evy unchecked")
+		return operands(info, args)
+	}
+	format := constant.StringVal(value)
+	params := args[1:]
+	used := make([]bool, len(params))
+	var out strings.Builder
+	var ops []string
+	next := 0
+	for n := 0; n < len(format); n++ {
+		if format[n] != '%' {
+			out.WriteByte(format[n])
+			continue
+		}
+		n++
+		start := n
+		for n < len(format) && strings.IndexByte("+-# 0", format[n]) >= 0 {
+			n++
+		}
+		flags := format[start:n]
+		if n < len(format) && format[n] == '[' {
+			end := strings.IndexByte(format[n:], ']')
+			index, err := strconv.Atoi(format[n+1 : n+max(end, 1)])
+			if end < 0 || err != nil || index < 1 || index > len(params) {
+				diagnose(pos, "bad argument index in format %q", format)
+				return operands(info, args)
+			}
+			next = index - 1
+			n += end + 1
+		}
+		if n < len(format) && format[n] == '*' {
+			diagnose(pos, "* widths and precisions are not supported")
+			return operands(info, args)
+		}
+		var width int
+		width, n = formatNum(format, n)
+		prec := -1
+		if n < len(format) && format[n] == '.' {
+			prec, n = formatNum(format, n+1)
+			prec = max(prec, 0)
+		}
+		if n >= len(format) {
+			diagnose(pos, "format %q ends with an incomplete verb", format)
+			out.WriteString("%%!(NOVERB)")
+			break
+		}
+		verb, size := utf8.DecodeRuneInString(format[n:])
+		n += size - 1
+		switch {
+		case verb == '%':
+			out.WriteString("%%")
+		case next >= len(params):
+			diagnose(pos, "format %%%c has no argument", verb)
+			fmt.Fprintf(&out, "%%%%!%c(MISSING)", verb)
+		case verb == 'T':
+			name := types.TypeString(info.TypeOf(params[next]), func(pkg *types.Package) string { return pkg.Name() })
+			out.WriteString(strings.ReplaceAll(name, "%", "%%"))
+			used[next] = true
+			next++
+		default:
+			evyVerb, op := formatVerb(info, pos, verb, flags, width, prec, params[next])
+			out.WriteString(evyVerb)
+			ops = append(ops, op)
+			used[next] = true
+			next++
+		}
+	}
+	for n, ok := range used {
+		if !ok {
+			diagnose(params[n].Pos(), "argument is not used by format %q", format)
+		}
+	}
+	return append([]string{fmt.Sprintf("%q", out.String())}, ops...)
+}
+
+// formatNum parses the decimal number at format[n:], returning -1 if there
+// is none, and the offset after it.
+func formatNum(format string, n int) (int, int) {
+	start := n
+	for n < len(format) && '0' <= format[n] && format[n] <= '9' {
+		n++
+	}
+	if n == start {
+		return -1, n
+	}
+	num, _ := strconv.Atoi(format[start:n])
+	return num, n
+}
+
+// formatVerb translates one formatted argument. It returns the Evy verb
+// printing the operand, which is the argument itself or the string a
+// helper formats it to.
+func formatVerb(info *types.Info, pos token.Pos, verb rune, flags string, width, prec int, arg ast.Expr) (string, string) {
+	t := info.TypeOf(arg)
+	x := translateOperand(info, arg)
+	if strings.ContainsAny(flags, "# ") {
+		diagnose(pos, "format flags %q are not supported", flags)
+	}
+	s := "" // the formatted string, if the argument is not printed as is
+	switch verb {
+	case 'v', 's', 't', 'w':
+		if prec >= 0 {
+			diagnose(pos, "precision is not supported for %%%c", verb)
+		}
+		if verb != 't' {
+			x = printValue(info, arg, x)
+		}
+	case 'd':
+		if !isInteger(t) {
+			diagnose(pos, "%%d of %s is not supported", t)
+		}
+		if isDuration(t) {
+			x = durationNs(x)
+		}
+	case 'f', 'F':
+		if !isNumeric(t) {
+			diagnose(pos, "%%%c of %s is not supported", verb, t)
+			break
+		}
+		if prec < 0 {
+			prec = 6
+		}
+		s = fmt.Sprintf("(%s %s %d)", useHelper("__fixed"), x, prec)
+	case 'x', 'X', 'o', 'b':
+		if !isInteger(t) {
+			diagnose(pos, "%%%c of %s is not supported", verb, t)
+			break
+		}
+		base := map[rune]int{'x': 16, 'X': 16, 'o': 8, 'b': 2}[verb]
+		s = fmt.Sprintf("(%s %s %d)", useHelper("__radix"), x, base)
+		if verb == 'X' {
+			s = "(upper " + s + ")"
+		}
+	case 'q':
+		if !isString(t) || flags != "" || width >= 0 || prec >= 0 {
+			diagnose(pos, "%%q is only supported for plain strings")
+		}
+		return "%q", x
+	default:
+		diagnose(pos, "format verb %%%c is not supported", verb)
+	}
+	if strings.Contains(flags, "+") && isNumeric(t) {
+		if s == "" {
+			s = "(sprint " + x + ")"
+		}
+		s = fmt.Sprintf("(%s %s %s)", useHelper("__signed"), s, x)
+	}
+	if width >= 0 {
+		if s == "" {
+			s = "(sprint " + x + ")"
+		}
+		left := strings.Contains(flags, "-")
+		zero := strings.Contains(flags, "0") && isNumeric(t)
+		s = fmt.Sprintf("(%s %s %d %t %t)", useHelper("__pad"), s, width, left, zero)
+	}
+	if s == "" {
+		return "%v", x
+	}
+	return "%v", s
+}
+
+func init() {
+	addHelpers(map[string]helper{
+		"__fixed": {src: `
+func __fixed:string x:num prec:num
+    a := x
+    if x < 0
+        a = -x
+    end
+    scale := pow 10 prec
+    n := round (a * scale)
+    ip := floor (n / scale)
+    s := sprint ip
+    if prec > 0
+        frac := sprint (n - ip * scale)
+        while (len frac) < prec
+            frac = "0" + frac
+        end
+        s = s + "." + frac
+    end
+    if x < 0
+        s = "-" + s
+    end
+    return s
+end`},
+		"__radix": {src: `
+func __radix:string x:num base:num
+    digits := "0123456789abcdefghijklmnopqrstuvwxyz"
+    n := x
+    if x < 0
+        n = -x
+    end
+    s := ""
+    while n >= base
+        s = digits[n % base] + s
+        n = floor (n / base)
+    end
+    s = digits[n] + s
+    if x < 0
+        s = "-" + s
+    end
+    return s
+end`},
+		"__signed": {src: `
+func __signed:string s:string x:num
+    if x >= 0
+        return "+" + s
+    end
+    return s
+end`},
+		"__pad": {src: `
+func __pad:string s:string width:num left:bool zero:bool
+    body := s
+    sign := ""
+    fill := " "
+    if zero and !left
+        fill = "0"
+        if (len body) > 0
+            if body[0] == "-" or body[0] == "+"
+                sign = body[0]
+                body = body[1:]
+            end
+        end
+    end
+    n := width - (len sign) - (len body)
+    while n > 0
+        if left
+            body = body + " "
+        else
+            body = fill + body
+        end
+        n = n - 1
+    end
+    return sign + body
+end`},
+	})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// randSeed, when set by -seed, replaces Evy's rand and rand1 with a
+// deterministic generator written in Evy, so a translated program makes
+// the same random choices on every run. rand.Seed and rand.NewSource
+// reseed it. The generator is the minimal standard Lehmer generator, whose
+// state stays exact in an Evy num; it does not reproduce the sequence of
+// Go's math/rand. All generators share its single state. Each draw is below
+// 2^31, so bounds beyond that combine two draws, and rand.Int and
+// rand.Int63 stay below 2^53, as do their values without -seed.
+var randSeed int64
+
+// seedRand registers the deterministic generator, starting from seed.
+func seedRand(seed int64) {
+	addHelpers(map[string]helper{
+		"__seed": {src: fmt.Sprintf("\n__seed := %d", lehmerState(seed))},
+		"__rand_next": {deps: []string{"__seed"}, src: `
+func __rand_next:num
+    __seed = (__seed * 48271) % 2147483647
+    return __seed
+end`},
+		"__rand": {deps: []string{"__rand_next"}, src: `
+func __rand:num n:num
+    r := (__rand_next)
+    if n > 2147483647
+        // A second draw widens the range to [0, 2^53 - 2^23).
+        r = (r - 1) * 4194304 + (__rand_next) % 4194304
+    end
+    return r % n
+end`},
+		"__rand1": {deps: []string{"__rand_next"}, src: `
+func __rand1:num
+    return ((__rand_next) - 1) / 2147483646
+end`},
+		"__rand_seed": {deps: []string{"__seed"}, src: `
+func __rand_seed seed:num
+    __seed = seed % 2147483647
+    if __seed < 0
+        __seed = __seed + 2147483647
+    end
+    if __seed == 0
+        __seed = 1
+    end
+end`},
+		"__rand_new": {deps: []string{"__rand_seed"}, src: `
+func __rand_new:{}any seed:num
+    __rand_seed seed
+    return {}
+end`},
+		"__perm": {deps: []string{"__rand"}, src: permSource("__rand")},
+	})
+}
+
+// lehmerState returns the generator state for a seed, which must lie in
+// [1, 2^31-2].
+func lehmerState(seed int64) int64 {
+	state := seed % 2147483647
+	if state < 0 {
+		state += 2147483647
+	}
+	if state == 0 {
+		state = 1
+	}
+	return state
+}
+
+// randCall returns the call of Evy's random builtin, or of its
+// deterministic replacement.
+func randCall(name string, args ...string) string {
+	if randSeed != 0 {
+		name = useHelper("__" + name)
+	}
+	if len(args) == 0 {
+		return "(" + name + ")"
+	}
+	return evyCall(name, args...)
+}
+
+// translateRandCall translates a call to a function of package math/rand
+// or a method of *rand.Rand.
+func translateRandCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	args := operands(info, call.Args)
+	switch name {
+	case "Intn", "Int63n", "Int31n":
+		return randCall("rand", args[0]), true
+	case "Int", "Int63":
+		return randCall("rand", "9007199254740992"), true
+	case "Int31":
+		return randCall("rand", "2147483648"), true
+	case "Float64", "Float32":
+		return randCall("rand1"), true
+	case "Perm":
+		return evyCall(useHelper("__perm"), args[0]), true
+	case "Shuffle":
+		// The swap function is a function value, so the loop is emitted
+		// inline, calling it through its apply function.
+		swap := applyName(info.TypeOf(call.Args[1]).Underlying().(*types.Signature))
+		i, j := newTemp("i"), newTemp("j")
+		lines := []string{
+			fmt.Sprintf("for %s := range (%s - 1) 0 (-1)", i, args[0]),
+			fmt.Sprintf("    %s := %s", j, randCall("rand", "("+i+" + 1)")),
+			fmt.Sprintf("    %s %s %s %s", swap, args[1], i, j),
+			"end",
+		}
+		return strings.Join(lines, "\n"), true
+	case "Seed":
+		if randSeed != 0 {
+			return evyCall(useHelper("__rand_seed"), args[0]), true
+		}
+		diagnose(call.Pos(), "Evy's rand cannot be seeded; translate with -seed for a deterministic generator")
+		return "", true
+	case "NewSource":
+		return args[0], true
+	case "New":
+		// A generator is an empty map; the state is global.
+		if randSeed != 0 {
+			return evyCall(useHelper("__rand_new"), args[0]), true
+		}
+		diagnose(call.Pos(), "Evy's rand cannot be seeded; translate with -seed for a deterministic generator")
+		return "{}", true
+	}
+	diagnose(call.Pos(), "rand.%s is not supported", name)
+	return "", false
+}
+
+func init() {
+	stdlibCalls["math/rand"] = translateRandCall
+	stdlibCalls["math/rand.Rand"] = translateRandCall
+	addHelpers(map[string]helper{
+		"__perm": {src: permSource("rand")},
+	})
+}
+
+// permSource returns the source of a helper implementing rand.Perm with
+// the given random builtin.
+func permSource(rand string) string {
+	return `
+func __perm:[]num n:num
+    m:[]num
+    for i := range n
+        j := ` + rand + ` (i + 1)
+        m = m + [0]
+        m[i] = m[j]
+        m[j] = i
+    end
+    return m
+end`
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// Evy forbids shadowing: a variable cannot be declared while another of
+// the same name is visible, and local names share the namespace of globals,
+// functions and builtins. The scopes of the translated Evy program are replayed over
+// the Go source, and every local declaration that would clash is renamed to
+// a fresh name used for the whole of its scope.
+
+// renames maps local variables to the Evy name they are declared under.
+var renames map[types.Object]string
+
+// localName returns the Evy name of a variable.
+func localName(obj types.Object) string {
+	if name, ok := renames[obj]; ok {
+		return name
+	}
+	return obj.Name()
+}
+
+type scopeWalker struct {
+	info    *types.Info
+	globals map[string]bool
+	scopes  []map[string]bool
+}
+
+func analyzeScopes(info *types.Info, file *ast.File) {
+	globals := map[string]bool{}
+	for name := range evyGlobals {
+		globals[name] = true
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			globals[funcName(info.Defs[d.Name].(*types.Func))] = true
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if valueSpec, ok := spec.(*ast.ValueSpec); ok {
+					for _, name := range valueSpec.Names {
+						if obj := info.Defs[name]; obj != nil {
+							globals[localName(obj)] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	for _, c := range closureOrder {
+		globals[c.name] = true
+	}
+	for _, decl := range file.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Body != nil {
+			w := &scopeWalker{info: info, globals: globals}
+			w.push()
+			if funcDecl.Recv != nil {
+				w.fields(funcDecl.Recv)
+			}
+			w.fields(funcDecl.Type.Params)
+			w.fields(funcDecl.Type.Results)
+			w.stmts(funcDecl.Body.List)
+		}
+	}
+	// Closures are walked after their enclosing function has settled the
+	// names of the variables they capture.
+	for _, c := range closureOrder {
+		w := &scopeWalker{info: info, globals: globals}
+		w.push()
+		for _, v := range c.free {
+			w.scopes[0][localName(v)] = true
+		}
+		w.fields(c.lit.Type.Params)
+		w.fields(c.lit.Type.Results)
+		w.stmts(c.lit.Body.List)
+	}
+	for obj, box := range boxes {
+		if name, ok := renames[obj]; ok {
+			boxes[obj] = strings.TrimSuffix(box, obj.Name()) + name
+		}
+	}
+}
+
+func (w *scopeWalker) push() { w.scopes = append(w.scopes, map[string]bool{}) }
+func (w *scopeWalker) pop()  { w.scopes = w.scopes[:len(w.scopes)-1] }
+
+func (w *scopeWalker) visible(name string) bool {
+	if w.globals[name] {
+		return true
+	}
+	for _, scope := range w.scopes {
+		if scope[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// declare declares a variable in the innermost scope, renaming it if its
+// name is taken or reserved; see evyIdent.
+func (w *scopeWalker) declare(id *ast.Ident) {
+	obj := w.info.Defs[id]
+	if obj == nil || id.Name == "_" {
+		return
+	}
+	base := evyIdent(obj.Name())
+	name := base
+	for n := 1; w.visible(name); n++ {
+		name = fmt.Sprintf("%s_%d", base, n)
+	}
+	if name != obj.Name() {
+		renames[obj] = name
+	}
+	w.scopes[len(w.scopes)-1][name] = true
+}
+
+func (w *scopeWalker) fields(list *ast.FieldList) {
+	if list == nil {
+		return
+	}
+	for _, field := range list.List {
+		for _, id := range field.Names {
+			w.declare(id)
+		}
+	}
+}
+
+func (w *scopeWalker) block(stmts []ast.Stmt) {
+	w.push()
+	w.stmts(stmts)
+	w.pop()
+}
+
+func (w *scopeWalker) stmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		w.stmt(stmt)
+	}
+}
+
+// stmt follows the scopes of the Evy each statement translates to; see
+// translateIfStmt, translateForStmt, translateRangeStmt and
+// translateSwitchStmt.
+func (w *scopeWalker) stmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		if s.Tok == token.DEFINE {
+			for _, lhs := range s.Lhs {
+				w.declare(lhs.(*ast.Ident))
+			}
+		}
+	case *ast.DeclStmt:
+		if gen, ok := s.Decl.(*ast.GenDecl); ok {
+			for _, spec := range gen.Specs {
+				if valueSpec, ok := spec.(*ast.ValueSpec); ok {
+					for _, name := range valueSpec.Names {
+						w.declare(name)
+					}
+				}
+			}
+		}
+	case *ast.BlockStmt:
+		// A nested block is inlined into the enclosing Evy block.
+		w.stmts(s.List)
+	case *ast.LabeledStmt:
+		w.stmt(s.Stmt)
+	case *ast.IfStmt:
+		if s.Init != nil {
+			w.stmt(s.Init)
+		}
+		w.block(s.Body.List)
+		if s.Else != nil {
+			w.push()
+			w.stmt(s.Else)
+			w.pop()
+		}
+	case *ast.ForStmt:
+		loop := countedLoop(w.info, s)
+		if s.Init != nil && loop == nil {
+			w.stmt(s.Init)
+		}
+		w.push()
+		if loop != nil {
+			w.declare(loop.v)
+		}
+		w.stmts(s.Body.List)
+		w.pop()
+	case *ast.RangeStmt:
+		w.push()
+		if s.Tok == token.DEFINE {
+			for _, expr := range []ast.Expr{s.Key, s.Value} {
+				if id, ok := expr.(*ast.Ident); ok {
+					w.declare(id)
+				}
+			}
+		}
+		w.stmts(s.Body.List)
+		w.pop()
+	case *ast.SwitchStmt:
+		if s.Init != nil {
+			w.stmt(s.Init)
+		}
+		for _, clause := range s.Body.List {
+			w.block(clause.(*ast.CaseClause).Body)
+		}
+	case *ast.TypeSwitchStmt:
+		if s.Init != nil {
+			w.stmt(s.Init)
+		}
+		for _, clause := range s.Body.List {
+			w.block(clause.(*ast.CaseClause).Body)
+		}
+	case *ast.SelectStmt:
+		for _, clause := range s.Body.List {
+			comm := clause.(*ast.CommClause)
+			w.push()
+			if comm.Comm != nil {
+				w.stmt(comm.Comm)
+			}
+			w.stmts(comm.Body)
+			w.pop()
+		}
+	}
+}
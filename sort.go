@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// Sorting and searching are done by generated helpers. Evy helpers cannot
+// be generic, so a helper is generated for each element type it is used
+// with, named after the type. Arrays are shared by reference in Evy, so
+// sorting in place works as in Go.
+
+// typedHelper returns the name of the helper base specialized to the Evy
+// element type elem, registering it on first use. src returns the source
+// for the helper's name and element type.
+func typedHelper(base, elem string, src func(name, elem string) string, deps ...string) string {
+	name := typedName(base, elem)
+	if _, ok := helperDefs[name]; !ok {
+		addHelpers(map[string]helper{name: {deps: deps, src: src(name, elem)}})
+	}
+	return useHelper(name)
+}
+
+func typedName(base, elem string) string {
+	return base + "_" + strings.NewReplacer("[]", "arr_", "{}", "map_").Replace(elem)
+}
+
+// elemType returns the Evy type of the elements of a slice or array.
+func elemType(info *types.Info, expr ast.Expr) string {
+	switch t := info.TypeOf(expr).Underlying().(type) {
+	case *types.Slice:
+		return evyType(t.Elem()).String()
+	case *types.Array:
+		return evyType(t.Elem()).String()
+	}
+	return "any"
+}
+
+// translateSortCall translates a call to a function of package sort.
+func translateSortCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	switch name {
+	case "Ints", "Float64s", "Strings":
+		return evyCall(typedHelper("__sort", elemType(info, call.Args[0]), sortSource), translateOperand(info, call.Args[0])), true
+	case "Slice", "SliceStable":
+		return translateSortSlice(info, call), true
+	case "Sort", "Stable":
+		return translateSortInterface(info, call)
+	case "SearchInts", "SearchStrings":
+		return evyCall(typedHelper("__search", elemType(info, call.Args[0]), searchSource), operands(info, call.Args)...), true
+	}
+	diagnose(call.Pos(), "sort.%s is not supported", name)
+	return "", false
+}
+
+// translateSlicesCall translates a call to a function of package slices.
+func translateSlicesCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	args := operands(info, call.Args)
+	switch name {
+	case "Sort":
+		return evyCall(typedHelper("__sort", elemType(info, call.Args[0]), sortSource), args...), true
+	case "Contains":
+		return "((" + evyCall(typedHelper("__index", elemType(info, call.Args[0]), indexSource), args...) + ") != -1)", true
+	case "Index":
+		return evyCall(typedHelper("__index", elemType(info, call.Args[0]), indexSource), args...), true
+	case "Reverse":
+		return evyCall(typedHelper("__reverse", elemType(info, call.Args[0]), reverseSource), args...), true
+	case "Max", "Min":
+		op := map[string]string{"Max": ">", "Min": "<"}[name]
+		return evyCall(typedHelper("__"+strings.ToLower(name), elemType(info, call.Args[0]), extremeSource(op), "__panic"), args...), true
+	case "Sorted", "Collect":
+		// The sequences of package maps are translated to arrays.
+		if name == "Collect" {
+			return args[0], true
+		}
+		elem := evyType(info.TypeOf(call).Underlying().(*types.Slice).Elem()).String()
+		sort := typedHelper("__sort", elem, sortSource)
+		return evyCall(typedHelper("__sorted", elem, sortedSource, sort), args...), true
+	}
+	diagnose(call.Pos(), "slices.%s is not supported", name)
+	return "", false
+}
+
+// translateMapsCall translates a call to a function of package maps. The
+// iterators Keys and Values return are translated to arrays.
+func translateMapsCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	switch name {
+	case "Keys", "Values":
+		m := info.TypeOf(call.Args[0]).Underlying().(*types.Map)
+		if name == "Keys" && !isString(m.Key()) {
+			diagnose(call.Pos(), "maps.Keys of a map with %s keys is not supported", m.Key())
+		}
+		elem := evyType(m.Elem()).String()
+		src := map[string]func(name, elem string) string{"Keys": keysSource, "Values": valuesSource}[name]
+		return evyCall(typedHelper("__"+strings.ToLower(name), elem, src), translateOperand(info, call.Args[0])), true
+	}
+	diagnose(call.Pos(), "maps.%s is not supported", name)
+	return "", false
+}
+
+// translateSortSlice lowers sort.Slice to a sort function specialized to
+// its comparator, an insertion sort that swaps elements in place, as the
+// comparator compares the elements at two indexes of the slice being
+// sorted. A function literal comparator is called directly as the
+// function it is lifted to.
+func translateSortSlice(info *types.Info, call *ast.CallExpr) string {
+	name := newTemp("sort_slice")
+	elem := elemType(info, call.Args[0])
+	args := []string{translateOperand(info, call.Args[0])}
+	params := []string{"__xs:[]" + elem}
+	var less string
+	if lit, ok := ast.Unparen(call.Args[1]).(*ast.FuncLit); ok {
+		c := closures[lit]
+		var names []string
+		for _, param := range freeParams(c) {
+			params = append(params, param)
+			names = append(names, param[:strings.Index(param, ":")])
+		}
+		args = append(args, captureArgs(c)...)
+		less = evyCall(c.name, names...)
+	} else {
+		params = append(params, "__less:[]any")
+		args = append(args, translateOperand(info, call.Args[1]))
+		less = evyCall(applyName(info.TypeOf(call.Args[1]).Underlying().(*types.Signature)), "__less")
+	}
+	src := translateSignature(name, params, "") + `    for i := range 1 (len __xs)
+        j := i
+        while j > 0
+            if !(` + less + ` j (j - 1))
+                break
+            end
+            t := __xs[j]
+            __xs[j] = __xs[j - 1]
+            __xs[j - 1] = t
+            j = j - 1
+        end
+    end
+end`
+	addHelpers(map[string]helper{name: {src: "\n" + src}})
+	return evyCall(useHelper(name), args...)
+}
+
+// translateSortInterface lowers sort.Sort over a type declared in the file
+// to an insertion sort calling its Len, Less and Swap methods.
+func translateSortInterface(info *types.Info, call *ast.CallExpr) (string, bool) {
+	t := info.TypeOf(call.Args[0])
+	methods := map[string]string{}
+	for _, m := range []string{"Len", "Less", "Swap"} {
+		obj, _, _ := types.LookupFieldOrMethod(t, true, nil, m)
+		fn, ok := obj.(*types.Func)
+		if !ok || !fileFuncs[fn] {
+			diagnose(call.Pos(), "sort.Sort is only supported for types declared in the file")
+			return "", false
+		}
+		methods[m] = funcName(fn)
+	}
+	name := newTemp("sort")
+	src := translateSignature(name, []string{"__data:" + evyType(t).String()}, "") + fmt.Sprintf(`    for i := range 1 (%s __data)
+        j := i
+        while j > 0
+            if !(%s __data j (j - 1))
+                break
+            end
+            %s __data j (j - 1)
+            j = j - 1
+        end
+    end
+end`, methods["Len"], methods["Less"], methods["Swap"])
+	addHelpers(map[string]helper{name: {src: "\n" + src}})
+	return evyCall(useHelper(name), translateOperand(info, call.Args[0])), true
+}
+
+// sortSource is a stable bottom-up merge sort.
+func sortSource(name, elem string) string {
+	return fmt.Sprintf(`
+func %s xs:[]%s
+    n := len xs
+    tmp := xs[:]
+    width := 1
+    while width < n
+        for lo := range 0 n (2 * width)
+            mid := min (lo + width) n
+            hi := min (lo + 2 * width) n
+            i := lo
+            j := mid
+            for k := range lo hi
+                left := false
+                if i < mid
+                    left = true
+                    if j < hi
+                        left = !(xs[j] < xs[i])
+                    end
+                end
+                if left
+                    tmp[k] = xs[i]
+                    i = i + 1
+                else
+                    tmp[k] = xs[j]
+                    j = j + 1
+                end
+            end
+        end
+        for k := range n
+            xs[k] = tmp[k]
+        end
+        width = width * 2
+    end
+end`, name, elem)
+}
+
+func sortedSource(name, elem string) string {
+	sort := typedName("__sort", elem)
+	return fmt.Sprintf(`
+func %s:[]%s xs:[]%s
+    sorted := xs[:]
+    %s sorted
+    return sorted
+end`, name, elem, elem, sort)
+}
+
+func searchSource(name, elem string) string {
+	return fmt.Sprintf(`
+func %s:num xs:[]%s x:%s
+    lo := 0
+    hi := len xs
+    while lo < hi
+        mid := floor ((lo + hi) / 2)
+        if xs[mid] < x
+            lo = mid + 1
+        else
+            hi = mid
+        end
+    end
+    return lo
+end`, name, elem, elem)
+}
+
+func indexSource(name, elem string) string {
+	return fmt.Sprintf(`
+func %s:num xs:[]%s x:%s
+    for i := range (len xs)
+        if xs[i] == x
+            return i
+        end
+    end
+    return -1
+end`, name, elem, elem)
+}
+
+func reverseSource(name, elem string) string {
+	return fmt.Sprintf(`
+func %s xs:[]%s
+    n := len xs
+    for i := range (floor (n / 2))
+        t := xs[i]
+        xs[i] = xs[n - 1 - i]
+        xs[n - 1 - i] = t
+    end
+end`, name, elem)
+}
+
+// extremeSource returns the source of a helper finding the element that
+// compares op to every other, panicking on an empty slice as Go does.
+func extremeSource(op string) func(name, elem string) string {
+	return func(name, elem string) string {
+		return fmt.Sprintf(`
+func %s:%s xs:[]%s
+    if (len xs) == 0
+        __panic "slices: empty list"
+    end
+    m := xs[0]
+    for x := range xs
+        if x %s m
+            m = x
+        end
+    end
+    return m
+end`, name, elem, elem, op)
+	}
+}
+
+func keysSource(name, elem string) string {
+	return fmt.Sprintf(`
+func %s:[]string m:{}%s
+    keys:[]string
+    for k := range m
+        keys = keys + [k]
+    end
+    return keys
+end`, name, elem)
+}
+
+func valuesSource(name, elem string) string {
+	return fmt.Sprintf(`
+func %s:[]%s m:{}%s
+    values:[]%s
+    for k := range m
+        values = values + [m[k]]
+    end
+    return values
+end`, name, elem, elem, elem)
+}
+
+func init() {
+	stdlibCalls["sort"] = translateSortCall
+	stdlibCalls["slices"] = translateSlicesCall
+	stdlibCalls["maps"] = translateMapsCall
+}
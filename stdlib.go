@@ -0,0 +1,87 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// stdlibFunc translates a call to a function of a standard library
+// package or to a Go builtin, given the function's name.
+type stdlibFunc func(info *types.Info, call *ast.CallExpr, name string) (string, bool)
+
+var (
+	// stdlibCalls holds the translations of standard library packages,
+	// keyed by import path, and of the methods of their types, keyed by
+	// import path and type name. Each file mapping a package registers it.
+	stdlibCalls = map[string]stdlibFunc{}
+	// builtinCalls holds the translations of Go builtins, keyed by name.
+	builtinCalls = map[string]stdlibFunc{}
+)
+
+// translateStdlibCall translates a call to a package-level function of a
+// mapped standard library package, or to a mapped Go builtin. Calls are
+// resolved through the type checker, so a user function named like a
+// library one is left alone.
+func translateStdlibCall(info *types.Info, call *ast.CallExpr) (string, bool) {
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		if b, ok := info.Uses[fun].(*types.Builtin); ok && builtinCalls[b.Name()] != nil {
+			return builtinCalls[b.Name()](info, call, b.Name())
+		}
+	case *ast.SelectorExpr:
+		fn, ok := info.Uses[fun.Sel].(*types.Func)
+		if !ok || fn.Pkg() == nil {
+			return "", false
+		}
+		key := fn.Pkg().Path()
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			named, ok := types.Unalias(deref(recv.Type())).(*types.Named)
+			if !ok {
+				return "", false
+			}
+			key += "." + named.Obj().Name()
+		}
+		if translate := stdlibCalls[key]; translate != nil {
+			return translate(info, call, fn.Name())
+		}
+	}
+	return "", false
+}
+
+// evyCall returns the Evy call of a function with translated arguments.
+func evyCall(name string, args ...string) string {
+	return strings.Join(append([]string{name}, args...), " ")
+}
+
+// operands translates call arguments as Evy operands.
+func operands(info *types.Info, args []ast.Expr) []string {
+	var ops []string
+	for _, arg := range args {
+		ops = append(ops, translateOperand(info, arg))
+	}
+	return ops
+}
+
+// isPkgVar reports whether expr denotes the named package-level variable,
+// such as os.Stdout.
+func isPkgVar(info *types.Info, expr ast.Expr, path, name string) bool {
+	sel, ok := ast.Unparen(expr).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	v, ok := info.Uses[sel.Sel].(*types.Var)
+	return ok && v.Pkg() != nil && v.Pkg().Path() == path && v.Name() == name
+}
+
+func isString(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsString != 0
+}
+
+func deref(t types.Type) types.Type {
+	if ptr, ok := t.(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// The parsing functions return their value and error as an array, as any
+// call with several results does; see resultType. The error is the message
+// Go would give, or false for nil.
+
+// translateStrconvCall translates a call to a function of package strconv.
+func translateStrconvCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	args := operands(info, call.Args)
+	switch name {
+	case "Itoa":
+		return evyCall("sprint", args[0]), true
+	case "Quote":
+		return evyCall("sprintf", `"%q"`, args[0]), true
+	case "Atoi":
+		return evyCall(useHelper("__parse_int"), args[0], "10", `"Atoi"`), true
+	case "ParseInt", "ParseUint":
+		if name == "ParseUint" {
+			diagnose(call.Pos(), "strconv.ParseUint accepts negative numbers in Evy")
+		}
+		return evyCall(useHelper("__parse_int"), args[0], args[1], fmt.Sprintf("%q", name)), true
+	case "ParseFloat":
+		return evyCall(useHelper("__parse_float"), args[0]), true
+	case "ParseBool":
+		return evyCall(useHelper("__parse_bool"), args[0]), true
+	case "FormatInt", "FormatUint":
+		if base := info.Types[call.Args[1]].Value; base != nil && constant.Compare(base, token.EQL, constant.MakeInt64(10)) {
+			return evyCall("sprint", args[0]), true
+		}
+		return evyCall(useHelper("__radix"), args[0], args[1]), true
+	case "FormatFloat":
+		format, prec := info.Types[call.Args[1]].Value, info.Types[call.Args[2]].Value
+		if format != nil && prec != nil {
+			f, _ := constant.Int64Val(format)
+			p, _ := constant.Int64Val(prec)
+			switch {
+			case p < 0 && (f == 'g' || f == 'f'):
+				return evyCall("sprint", args[0]), true
+			case f == 'f':
+				return evyCall(useHelper("__fixed"), args[0], args[2]), true
+			}
+		}
+		diagnose(call.Pos(), "strconv.FormatFloat is only supported with format 'f' or shortest 'g'")
+		return evyCall("sprint", args[0]), true
+	}
+	diagnose(call.Pos(), "strconv.%s is not supported", name)
+	return "", false
+}
+
+func init() {
+	stdlibCalls["strconv"] = translateStrconvCall
+	addHelpers(map[string]helper{
+		"__parse_int": {src: `
+func __parse_int:[]any s:string base:num fn:string
+    msg := sprintf "strconv.%v: parsing %q: invalid syntax" fn s
+    digits := "0123456789abcdefghijklmnopqrstuvwxyz"
+    body := s
+    neg := false
+    if (len body) > 0
+        if body[0] == "+" or body[0] == "-"
+            neg = body[0] == "-"
+            body = body[1:]
+        end
+    end
+    b := base
+    if b == 0
+        b = 10
+        if (len body) > 2
+            prefix := lower body[:2]
+            if prefix == "0x"
+                b = 16
+            else if prefix == "0o"
+                b = 8
+            else if prefix == "0b"
+                b = 2
+            end
+            if b != 10
+                body = body[2:]
+            end
+        end
+    end
+    if body == ""
+        return [0 msg]
+    end
+    n := 0
+    for c := range body
+        d := index digits (lower c)
+        if d < 0 or d >= b
+            return [0 msg]
+        end
+        n = n * b + d
+    end
+    if neg
+        n = -n
+    end
+    return [n false]
+end`},
+		"__parse_float": {src: `
+func __parse_float:[]any s:string
+    n := str2num s
+    if err
+        return [0 (sprintf "strconv.ParseFloat: parsing %q: invalid syntax" s)]
+    end
+    return [n false]
+end`},
+		"__parse_bool": {src: `
+func __parse_bool:[]any s:string
+    if s == "1" or s == "t" or s == "T" or s == "TRUE" or s == "true" or s == "True"
+        return [true false]
+    end
+    if s == "0" or s == "f" or s == "F" or s == "FALSE" or s == "false" or s == "False"
+        return [false false]
+    end
+    return [false (sprintf "strconv.ParseBool: parsing %q: invalid syntax" s)]
+end`},
+	})
+}
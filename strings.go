@@ -0,0 +1,128 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+)
+
+// stringsBuiltins are the strings functions with an Evy builtin of the same
+// arguments and semantics.
+var stringsBuiltins = map[string]string{
+	"Split":      "split",
+	"Join":       "join",
+	"ToUpper":    "upper",
+	"ToLower":    "lower",
+	"Index":      "index",
+	"HasPrefix":  "startswith",
+	"HasSuffix":  "endswith",
+	"Trim":       "trim",
+	"ReplaceAll": "replace",
+}
+
+// stringsHelpers are the strings functions implemented by a generated
+// helper of the same arguments.
+var stringsHelpers = map[string]string{
+	"Repeat": "__repeat",
+	"Fields": "__fields",
+	"Count":  "__count",
+}
+
+// translateStringsCall translates a call to a function of package strings.
+func translateStringsCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	args := operands(info, call.Args)
+	if builtin, ok := stringsBuiltins[name]; ok {
+		return evyCall(builtin, args...), true
+	}
+	if helper, ok := stringsHelpers[name]; ok {
+		return evyCall(useHelper(helper), args...), true
+	}
+	switch name {
+	case "Contains":
+		return "((index " + args[0] + " " + args[1] + ") != -1)", true
+	case "TrimSpace":
+		return evyCall("trim", args[0], `" \t\n\r"`), true
+	case "Replace":
+		// A negative count replaces every instance, as ReplaceAll does.
+		if n := info.Types[call.Args[3]].Value; n != nil && constant.Sign(n) < 0 {
+			return evyCall("replace", args[:3]...), true
+		}
+		return evyCall(useHelper("__replacen"), args...), true
+	}
+	diagnose(call.Pos(), "strings.%s is not supported", name)
+	return "", false
+}
+
+func init() {
+	stdlibCalls["strings"] = translateStringsCall
+	addHelpers(map[string]helper{
+		"__repeat": {deps: []string{"__panic"}, src: `
+func __repeat:string s:string n:num
+    if n < 0
+        __panic "strings: negative Repeat count"
+    end
+    result := ""
+    for range n
+        result = result + s
+    end
+    return result
+end`},
+		"__fields": {src: `
+func __fields:[]string s:string
+    fields:[]string
+    field := ""
+    for c := range s
+        if c == " " or c == "\t" or c == "\n" or c == "\r"
+            if field != ""
+                fields = fields + [field]
+                field = ""
+            end
+        else
+            field = field + c
+        end
+    end
+    if field != ""
+        fields = fields + [field]
+    end
+    return fields
+end`},
+		"__count": {src: `
+func __count:num s:string sub:string
+    if sub == ""
+        return (len s) + 1
+    end
+    return (len (split s sub)) - 1
+end`},
+		"__replacen": {src: `
+func __replacen:string s:string old:string new:string n:num
+    if n < 0
+        return replace s old new
+    end
+    if old == ""
+        result := ""
+        count := 0
+        for c := range s
+            if count < n
+                result = result + new
+                count = count + 1
+            end
+            result = result + c
+        end
+        if count < n
+            result = result + new
+        end
+        return result
+    end
+    parts := split s old
+    result := parts[0]
+    for i := range 1 (len parts)
+        if i <= n
+            result = result + new + parts[i]
+        else
+            result = result + old + parts[i]
+        end
+    end
+    return result
+end`},
+	})
+}
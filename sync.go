@@ -0,0 +1,363 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"maps"
+	"strings"
+)
+
+// Many programs use sync.WaitGroup, sync.Mutex and sync.Once only to wait
+// for goroutines that never talk to each other. When a file coordinates its
+// goroutines with sync alone, every go statement runs its function to
+// completion in place and the sync primitives are erased: a WaitGroup has
+// nothing left to wait for, a Mutex cannot be contended, and a Once becomes
+// a bool flag guarding the call.
+//
+// When goroutines run under the scheduler instead, a WaitGroup is a map
+// holding its counter, and Wait suspends the task until it drops to zero.
+// The other primitives are erased as before: tasks only switch where they
+// block, so a Mutex is still never contended. A file also runs under the
+// scheduler when a goroutine reads variables written after the go statement
+// starting it, which it would miss if run in place.
+
+// sequential reports that the file being translated runs goroutines in place
+// instead of under the scheduler.
+var sequential bool
+
+// syncTypes are the sync primitives that can be erased.
+var syncTypes = map[string]bool{"WaitGroup": true, "Mutex": true, "RWMutex": true, "Once": true}
+
+// syncNoOps are the methods erased outright.
+var syncNoOps = map[string]bool{
+	"Add": true, "Done": true, "Wait": true,
+	"Lock": true, "Unlock": true, "RLock": true, "RUnlock": true,
+}
+
+// syncTypeName returns the name of the sync primitive t is, or points to.
+func syncTypeName(t types.Type) string {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "sync" || !syncTypes[named.Obj().Name()] {
+		return ""
+	}
+	return named.Obj().Name()
+}
+
+func isSyncType(t types.Type) bool {
+	return t != nil && syncTypeName(t) != ""
+}
+
+// isErasedSync reports whether values of type t are erased, along with
+// the parameters, arguments and fields holding them.
+func isErasedSync(t types.Type) bool {
+	return isSyncType(t) && (sequential || syncTypeName(t) != "WaitGroup")
+}
+
+// isWait reports whether call waits for a WaitGroup the scheduler runs.
+func isWait(info *types.Info, call *ast.CallExpr) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "Wait" && syncTypeName(info.TypeOf(sel.X)) == "WaitGroup" && !isErasedSync(info.TypeOf(sel.X))
+}
+
+// analyzeSync decides whether the file can be run sequentially and reports
+// the sync usage that only makes sense when goroutines run concurrently.
+func analyzeSync(info *types.Info, file *ast.File) {
+	usesSync, usesChans := false, false
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Ident:
+			if obj := info.Uses[n]; obj != nil && isSyncType(obj.Type()) {
+				usesSync = true
+			}
+		case *ast.FuncDecl:
+			usesChans = usesChans || (n.Body != nil && blocksOnChan(info, n.Body))
+		case *ast.CallExpr:
+			if id, ok := n.Fun.(*ast.Ident); ok && id.Name == "make" && len(n.Args) > 0 {
+				_, isChan := info.TypeOf(n.Args[0]).Underlying().(*types.Chan)
+				usesChans = usesChans || isChan
+			}
+		}
+		return true
+	})
+	sequential = usesSync && !usesChans
+
+	goTargets := map[string]bool{}
+	funcDecls := map[string]*ast.FuncDecl{}
+	for _, decl := range file.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Recv == nil && funcDecl.Body != nil {
+			funcDecls[funcDecl.Name.Name] = funcDecl
+		}
+	}
+	for _, decl := range file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Body == nil {
+			continue
+		}
+		ast.Inspect(funcDecl.Body, func(node ast.Node) bool {
+			goStmt, ok := node.(*ast.GoStmt)
+			if !ok {
+				return true
+			}
+			var fn ast.Node
+			switch target := goStmt.Call.Fun.(type) {
+			case *ast.FuncLit:
+				fn = target
+			case *ast.Ident:
+				goTargets[target.Name] = true
+				if funcDecls[target.Name] != nil {
+					fn = funcDecls[target.Name]
+				}
+			}
+			if fn == nil {
+				return true
+			}
+			shared := sharedVars(info, fn)
+			if readsLaterWrites(info, funcDecl.Body, goStmt, shared) {
+				// Under the scheduler the goroutine only starts once the
+				// function blocks, after the writes it may depend on.
+				sequential = false
+			}
+			diagnoseSharedLoops(info, fn, shared)
+			return true
+		})
+	}
+	for _, decl := range file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Body == nil {
+			continue
+		}
+		locked := map[string]token.Pos{}
+		var order []string
+		ast.Inspect(funcDecl.Body, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || !isSyncType(info.TypeOf(sel.X)) {
+				return true
+			}
+			recv := types.ExprString(sel.X)
+			switch sel.Sel.Name {
+			case "Wait":
+				if sequential && goTargets[funcDecl.Name.Name] {
+					diagnose(call.Pos(), "goroutine %s waits for other goroutines; the program depends on concurrent execution", funcDecl.Name.Name)
+				}
+			case "Lock", "RLock":
+				if _, ok := locked[recv]; !ok {
+					order = append(order, recv)
+				}
+				locked[recv] = call.Pos()
+			case "Unlock", "RUnlock":
+				delete(locked, recv)
+			}
+			return true
+		})
+		for _, recv := range order {
+			if pos, ok := locked[recv]; ok {
+				diagnose(pos, "%s is locked in %s but never unlocked there; the program depends on concurrent execution", recv, funcDecl.Name.Name)
+			}
+		}
+	}
+}
+
+// sharedVars returns the variables the function fn reads but does not
+// declare, captured or package-level, other than sync primitives.
+func sharedVars(info *types.Info, fn ast.Node) map[types.Object]bool {
+	shared := map[types.Object]bool{}
+	ast.Inspect(fn, func(node ast.Node) bool {
+		id, ok := node.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if v, ok := info.Uses[id].(*types.Var); ok && !v.IsField() && !isSyncType(v.Type()) &&
+			(v.Pos() < fn.Pos() || v.Pos() >= fn.End()) {
+			shared[v] = true
+		}
+		return true
+	})
+	return shared
+}
+
+// readsLaterWrites reports whether body writes any of the shared variables
+// of a goroutine after the go statement starting it. Run in place, the
+// goroutine would finish before seeing those writes.
+func readsLaterWrites(info *types.Info, body *ast.BlockStmt, goStmt *ast.GoStmt, shared map[types.Object]bool) bool {
+	found := false
+	ast.Inspect(body, func(node ast.Node) bool {
+		if found || node == nil || node.End() <= goStmt.End() {
+			return false
+		}
+		var lhs []ast.Expr
+		switch n := node.(type) {
+		case *ast.AssignStmt:
+			lhs = n.Lhs
+		case *ast.IncDecStmt:
+			lhs = []ast.Expr{n.X}
+		}
+		if node.Pos() >= goStmt.End() {
+			for obj := range lhsRoots(info, lhs) {
+				found = found || shared[obj]
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// diagnoseSharedLoops reports the loops in the goroutine function fn that
+// wait for its shared variables to change: their condition reads shared
+// state and nothing the loop writes itself, and they never block. Tasks
+// only switch where they block, so such a loop never sees another
+// goroutine's write.
+func diagnoseSharedLoops(info *types.Info, fn ast.Node, shared map[types.Object]bool) {
+	ast.Inspect(fn, func(node ast.Node) bool {
+		loop, ok := node.(*ast.ForStmt)
+		if !ok || blocksOnChan(info, loop.Body) {
+			return true
+		}
+		written := map[types.Object]bool{}
+		ast.Inspect(loop, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.AssignStmt:
+				maps.Copy(written, lhsRoots(info, n.Lhs))
+			case *ast.IncDecStmt:
+				maps.Copy(written, lhsRoots(info, []ast.Expr{n.X}))
+			}
+			return true
+		})
+		conds := []ast.Expr{loop.Cond}
+		if loop.Cond == nil {
+			for _, stmt := range loop.Body.List {
+				if ifStmt, ok := stmt.(*ast.IfStmt); ok {
+					conds = append(conds, ifStmt.Cond)
+				}
+			}
+		}
+		for _, cond := range conds {
+			if obj := waitsOn(info, cond, shared, written); obj != nil {
+				diagnose(loop.Pos(), "goroutine loops on shared %s; the program depends on concurrent execution", obj.Name())
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// waitsOn returns a shared variable cond reads when it reads none that the
+// loop writes, or nil.
+func waitsOn(info *types.Info, cond ast.Expr, shared, written map[types.Object]bool) types.Object {
+	if cond == nil {
+		return nil
+	}
+	var found types.Object
+	local := false
+	ast.Inspect(cond, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok {
+			obj := info.Uses[id]
+			local = local || written[obj]
+			if found == nil && shared[obj] {
+				found = obj
+			}
+		}
+		return true
+	})
+	if local {
+		return nil
+	}
+	return found
+}
+
+// translateSyncCall erases calls to sync methods and inlines Once.Do.
+func translateSyncCall(info *types.Info, call *ast.CallExpr) (string, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !isSyncType(info.TypeOf(sel.X)) {
+		return "", false
+	}
+	if !isErasedSync(info.TypeOf(sel.X)) {
+		return translateWaitGroupCall(info, call), true
+	}
+	switch {
+	case syncNoOps[sel.Sel.Name]:
+		return "", true
+	case sel.Sel.Name == "TryLock" || sel.Sel.Name == "TryRLock":
+		return "true", true
+	case sel.Sel.Name == "Do":
+		once := translateLhs(info, sel.X)
+		var buf strings.Builder
+		buf.WriteString("if !" + translateExpr(info, sel.X) + "\n")
+		buf.WriteString(i(once + " = true\n" + translateExpr(info, &ast.CallExpr{Fun: call.Args[0]})))
+		buf.WriteString("\nend")
+		return buf.String(), true
+	}
+	diagnose(call.Pos(), "sync method %s is not supported", sel.Sel.Name)
+	return "", true
+}
+
+// translateWaitGroupCall translates a method call on a WaitGroup the
+// scheduler runs. Inside a task, Wait suspends it; see taskFrame.wait.
+func translateWaitGroupCall(info *types.Info, call *ast.CallExpr) string {
+	sel := call.Fun.(*ast.SelectorExpr)
+	wg := translateExpr(info, sel.X)
+	switch sel.Sel.Name {
+	case "Add":
+		return evyCall(useHelper("__wg_add"), wg, translateOperand(info, call.Args[0]))
+	case "Done":
+		return evyCall(useHelper("__wg_add"), wg, "-1")
+	case "Wait":
+		return evyCall(useHelper("__wg_wait"), wg)
+	}
+	diagnose(call.Pos(), "sync method %s is not supported", sel.Sel.Name)
+	return ""
+}
+
+// translateSyncDecl erases declarations of sync primitives. A Once becomes
+// the flag recording whether it has run, and a WaitGroup the scheduler
+// runs a new counter.
+func translateSyncDecl(info *types.Info, names []*ast.Ident) (string, bool) {
+	var lines []string
+	for _, name := range names {
+		obj := info.ObjectOf(name)
+		if obj == nil || !isSyncType(obj.Type()) {
+			return "", false
+		}
+		value := "false"
+		switch {
+		case !isErasedSync(obj.Type()):
+			value = zeroValue(obj.Type())
+		case syncTypeName(obj.Type()) != "Once":
+			continue
+		}
+		lines = append(lines, declareVar(info, name, value))
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+func init() {
+	addHelpers(map[string]helper{
+		"__wait_group": {src: `
+func __wait_group:{}any
+    wg:{}any
+    wg.n = 0
+    return wg
+end`},
+		"__wg_add": {deps: []string{"__panic", "__sched"}, src: `
+func __wg_add wg:{}any delta:num
+    wg.n = wg.n.(num) + delta
+    if wg.n.(num) < 0
+        __panic "sync: negative WaitGroup counter"
+    end
+    __progress = true
+end`},
+		"__wg_wait": {deps: []string{"__deadlock"}, src: `
+func __wg_wait wg:{}any
+    if wg.n.(num) > 0
+        __deadlock
+    end
+end`},
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+func quicksort(a []int) {
+	if len(a) < 2 {
+		return
+	}
+	p := a[len(a)-1]
+	i := 0
+	for j := 0; j < len(a)-1; j++ {
+		if a[j] < p {
+			a[i], a[j] = a[j], a[i]
+			i++
+		}
+	}
+	a[i], a[len(a)-1] = a[len(a)-1], a[i]
+	quicksort(a[:i])
+	quicksort(a[i+1:])
+}
+
+func main() {
+	xs := []int{5, 2, 8, 1, 9}
+	quicksort(xs)
+	fmt.Println(xs)
+	ys := []int{9, 3, 7, 1}
+	sort.Ints(ys[1:])
+	fmt.Println(ys)
+	zs := []int{1, 2, 3}
+	copy(zs[1:], []int{7, 8})
+	fmt.Println(zs)
+}
@@ -0,0 +1,30 @@
+package main
+
+import "fmt"
+
+func main() {
+	a, b := 1, 2
+	a, b = b, a
+	fmt.Println(a, b)
+
+	xs := []int{1, 2, 3}
+	i := 0
+	xs[i], i = 10, 1
+	fmt.Println(xs, i)
+
+	k := 0
+	next := func() int {
+		k++
+		return k
+	}
+	ys := []int{0, 0, 0}
+	ys[next()] += 5
+	ys[next()]++
+	fmt.Println(ys, k)
+
+	counts := map[string]int{}
+	counts["a"]++
+	counts["b"] += 2
+	counts["a"] *= 3
+	fmt.Println(counts["a"], counts["b"], counts["c"])
+}
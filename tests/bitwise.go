@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+func main() {
+	a, b := 12, 10
+	fmt.Println(a&b, a|b, a^b, a&^b, a<<2, a>>1, ^a)
+	flags := 0
+	flags |= 1 << 3
+	flags &^= 1 << 3
+	fmt.Println(flags, 1<<10)
+	n := 0
+	for x := 255; x != 0; x &= x - 1 {
+		n++
+	}
+	fmt.Println(n)
+}
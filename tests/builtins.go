@@ -0,0 +1,33 @@
+package main
+
+import "fmt"
+
+type point struct{ x, y int }
+
+func main() {
+	xs := make([]int, 3)
+	xs = append(xs, 4, 5)
+	ys := make([]int, 2)
+	n := copy(ys, xs[3:])
+	fmt.Println(xs, ys, n, len(xs), cap(xs))
+	fmt.Println(min(3, 1, 2), max(2.5, 1))
+
+	seen := map[int]bool{1: true}
+	seen[3] = true
+	delete(seen, 1)
+	for k := range seen {
+		fmt.Println(k + 1)
+	}
+	counts := make(map[rune]int)
+	for _, r := range []rune("abca") {
+		counts[r]++
+	}
+	if c, ok := counts['a']; ok {
+		fmt.Println(c)
+	}
+	grid := make([][]point, 2)
+	grid[0] = append(grid[0], point{1, 2})
+	clear(xs)
+	clear(counts)
+	fmt.Println(xs, len(counts), len(grid[0]))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+var greet = func(name string) string {
+	return "hello, " + name
+}
+
+func counter() func() int {
+	n := 0
+	return func() int {
+		n++
+		return n
+	}
+}
+
+func main() {
+	next := counter()
+	next()
+	fmt.Println(next())
+
+	base := 10
+	add := func(x int) int { return base + x }
+	base = 20
+	fmt.Println(add(1))
+
+	var mu sync.Mutex
+	var once sync.Once
+	total := 0
+	record := func(x int) {
+		mu.Lock()
+		total += x
+		mu.Unlock()
+		once.Do(func() { fmt.Println("first") })
+	}
+	record(1)
+	record(2)
+	fmt.Println(total)
+	fmt.Println(greet("bo"))
+}
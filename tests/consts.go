@@ -0,0 +1,22 @@
+package main
+
+import "fmt"
+
+const (
+	Pi     = 3.14159
+	Big    = 1 << 40
+	Tiny   = Big >> 38
+	Greet  = "hello, " + "world"
+	Ratio  = 10 / 4
+	FRatio = 10 / 4.0
+)
+
+const exact int64 = 1<<53 - 1
+
+func main() {
+	fmt.Println(Pi*2, Big, Tiny, Greet, Ratio, FRatio)
+	fmt.Println(exact, len(Greet))
+	const local = 1<<52 + 1
+	var f float64 = local
+	fmt.Println(f)
+}
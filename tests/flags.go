@@ -0,0 +1,24 @@
+// golang2evy: -prompt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	n := flag.Int("n", 3, "number of greetings")
+	name := flag.String("name", "world", "who to greet")
+	var loud bool
+	flag.BoolVar(&loud, "loud", false, "shout")
+	flag.Parse()
+	for i := 0; i < *n; i++ {
+		greeting := "hello, " + *name
+		if loud {
+			greeting += "!"
+		}
+		fmt.Println(greeting)
+	}
+	fmt.Println(len(os.Args) > 0, flag.NArg(), flag.Arg(0))
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+type Weekday int
+
+func (d Weekday) String() string {
+	return [...]string{"Sun", "Mon", "Tue"}[d]
+}
+
+func Println(s string) string { return s + "!" }
+
+func main() {
+	fmt.Print("a", "b", 1, 2, "c\n")
+	fmt.Println("a", 1, true)
+	s := fmt.Sprint(1, 2, "x", 3)
+	t := fmt.Sprintln("y", 4)
+	fmt.Print(s, t)
+	fmt.Fprintln(os.Stderr, "warning")
+	err := errors.New("boom")
+	fmt.Println(err, Println("own"))
+	d := Weekday(1)
+	fmt.Println(d, Weekday(2))
+	println("builtin", 3)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type op func(int, int) int
+
+func add(a, b int) int { return a + b }
+func mul(a, b int) int { return a * b }
+
+func apply(f func(string) string, s string) string {
+	if f == nil {
+		return s
+	}
+	return f(s)
+}
+
+func main() {
+	ops := map[string]op{"add": add, "mul": mul}
+	fmt.Println(ops["add"](2, 3), ops["mul"](2, 3))
+
+	up := strings.ToUpper
+	fmt.Println(up("hi"), apply(strings.TrimSpace, "  x  "), apply(nil, "y"))
+	itoa := strconv.Itoa
+	fmt.Println(itoa(42))
+
+	var cb func()
+	if cb == nil {
+		fmt.Println("no callback")
+	}
+}
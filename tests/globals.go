@@ -0,0 +1,41 @@
+package main
+
+import "fmt"
+
+type Weekday int
+
+const (
+	Sunday Weekday = iota
+	Monday
+	Tuesday
+)
+
+const (
+	_  = iota
+	KB = 1 << (10 * iota)
+	MB
+)
+
+type Point struct {
+	X, Y int
+	Name string
+}
+
+var (
+	count  int
+	names  []string
+	grid   [3][2]int
+	origin Point
+	big    [20]int
+)
+
+func main() {
+	count++
+	names = append(names, "a")
+	grid[1][0] = 5
+	fmt.Println(Sunday, Monday, Tuesday, KB, MB)
+	fmt.Println(count, names, grid, origin.Name == "", len(big))
+	var local [4]string
+	p := Point{X: 1}
+	fmt.Println(len(local), p.Y)
+}
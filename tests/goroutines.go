@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func say(s string) {
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		fmt.Println(s)
+	}
+}
+
+func sum(nums []int, c chan int) {
+	total := 0
+	for _, n := range nums {
+		total += n
+	}
+	c <- total
+}
+
+func produce(n int) <-chan int {
+	c := make(chan int)
+	go func() {
+		for i := 0; i < n; i++ {
+			c <- i
+		}
+		close(c)
+	}()
+	return c
+}
+
+func consume(c <-chan int) int {
+	total := 0
+	for v := range c {
+		total += v
+	}
+	return total
+}
+
+func main() {
+	go say("world")
+	say("hello")
+
+	nums := []int{7, 2, 8, -9, 4, 0}
+	c := make(chan int)
+	go sum(nums[:len(nums)/2], c)
+	go sum(nums[len(nums)/2:], c)
+	x, y := <-c, <-c
+	fmt.Println(x, y, x+y)
+
+	fmt.Println(consume(produce(5)))
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var name string
+	var age int
+	fmt.Print("name and age: ")
+	n, err := fmt.Scan(&name, &age)
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	fmt.Println(n, name, age+1)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Split(bufio.ScanWords)
+	count := 0
+	for scanner.Scan() {
+		fmt.Println(scanner.Text())
+		count++
+	}
+	fmt.Println(count)
+}
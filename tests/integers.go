@@ -0,0 +1,14 @@
+package main
+
+import "fmt"
+
+func main() {
+	a, b := -7, 2
+	fmt.Println(a/b, a%b, 7/2, -7%3)
+	f := 3.9
+	fmt.Println(int(f), int(-f), float64(a)/2)
+	fmt.Println(string('x'), string(rune(65+a+8)))
+	rs := []rune("hello")
+	rs[0] = 'H'
+	fmt.Println(string(rs), len(rs))
+}
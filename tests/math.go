@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+func main() {
+	fmt.Println(math.Sqrt(16), math.Pow(2, 10), math.Abs(-3.5))
+	fmt.Println(math.Floor(2.7), math.Ceil(2.1), math.Round(2.5), math.Trunc(-2.7))
+	fmt.Println(math.Max(3, 7), math.Min(3, 7), math.Mod(7, 3))
+	fmt.Println(math.Pi, math.MaxInt32, math.Inf(1) > 0)
+	fmt.Printf("%.4f %.4f\n", math.Sin(math.Pi/2), math.Log(math.E))
+	fmt.Println(math.Hypot(3, 4), math.IsNaN(math.NaN()))
+}
@@ -0,0 +1,20 @@
+// golang2evy: -names snake
+package main
+
+import "fmt"
+
+type userRecord struct {
+	FirstName string
+	userID    int
+}
+
+func fullName(r userRecord) string {
+	return r.FirstName + "#" + fmt.Sprint(r.userID)
+}
+
+func main() {
+	myRecord := userRecord{FirstName: "Ann", userID: 7}
+	maxCount := 2
+	HTTPServer := "srv"
+	fmt.Println(fullName(myRecord), maxCount, HTTPServer)
+}
@@ -0,0 +1,18 @@
+package main
+
+import "fmt"
+
+type Celsius float64
+
+func main() {
+	name, n, f := "Go", 42, 3.14159
+	fmt.Printf("%s has %d items costing %.2f\n", name, n, f)
+	fmt.Printf("|%5d|%-5d|%05d|\n", n, n, n)
+	fmt.Printf("%x %X %o %b\n", 255, 255, 8, 5)
+	fmt.Printf("%q %v %t %%\n", name, []int{1, 2}, true)
+	fmt.Printf("%8.3f|%+d\n", f, n)
+	s := fmt.Sprintf("%T %T %T", n, name, Celsius(f))
+	fmt.Println(s)
+	err := fmt.Errorf("code %d", n)
+	fmt.Printf("err: %v\n", err)
+}
@@ -0,0 +1,19 @@
+// golang2evy: -seed 1
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+func main() {
+	dice := rand.Intn(6) + 1
+	fmt.Println(dice >= 1 && dice <= 6)
+	f := rand.Float64()
+	fmt.Println(f >= 0 && f < 1)
+	xs := []int{1, 2, 3, 4}
+	rand.Shuffle(len(xs), func(i, j int) { xs[i], xs[j] = xs[j], xs[i] })
+	p := rand.Perm(3)
+	fmt.Println(len(xs), len(p))
+	fmt.Println(rand.Int63() > 1<<31)
+}
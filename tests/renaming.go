@@ -0,0 +1,18 @@
+package main
+
+import "fmt"
+
+func print(end string) string {
+	return "[" + end + "]"
+}
+
+func main() {
+	len := 3
+	func_ := "f"
+	num := 1.5
+	rand := len + 1
+	fmt.Println(print("x"), len, func_, num, rand)
+	var string_ string = "s"
+	end := string_ + "!"
+	fmt.Println(end)
+}
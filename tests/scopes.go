@@ -0,0 +1,34 @@
+package main
+
+import "fmt"
+
+var err error
+
+func divide(a, b int) (int, error) {
+	if b == 0 {
+		return 0, fmt.Errorf("divide by zero")
+	}
+	return a / b, nil
+}
+
+func main() {
+	x := 1
+	if x := 2; x > 1 {
+		fmt.Println("inner", x)
+	}
+	fmt.Println("outer", x)
+
+	q, err := divide(7, 2)
+	r, err := divide(1, 0)
+	fmt.Println(q, r, err)
+
+	for i := 0; i < 2; i++ {
+		x := i * 10
+		fmt.Println(x)
+	}
+	{
+		x := "shadow"
+		fmt.Println(x)
+	}
+	fmt.Println(x, err == nil)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+)
+
+type person struct {
+	name string
+	age  int
+}
+
+func main() {
+	xs := []int{3, 1, 2}
+	sort.Ints(xs)
+	names := []string{"b", "c", "a"}
+	sort.Strings(names)
+	fmt.Println(xs, names)
+
+	people := []person{{"Al", 30}, {"Bo", 25}}
+	sort.Slice(people, func(i, j int) bool { return people[i].age < people[j].age })
+	fmt.Println(people[0].name)
+
+	ys := []int{5, 4, 6}
+	slices.Sort(ys)
+	fmt.Println(ys, slices.Contains(ys, 4), slices.Index(ys, 6), slices.Max(ys))
+
+	ages := map[string]int{"x": 1, "y": 2}
+	keys := slices.Sorted(maps.Keys(ages))
+	fmt.Println(keys)
+}
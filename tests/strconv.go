@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func main() {
+	n, err := strconv.Atoi("42")
+	fmt.Println(n+1, err)
+	_, err = strconv.Atoi("x")
+	fmt.Println(err)
+	if err != nil {
+		fmt.Println("bad input")
+	}
+	f, _ := strconv.ParseFloat("2.5", 64)
+	b, _ := strconv.ParseBool("true")
+	fmt.Println(f*2, b)
+	fmt.Println(strconv.Itoa(7)+"!", strconv.Quote("hi"), strconv.FormatInt(255, 16))
+	var e error
+	fmt.Printf("%v\n", e)
+}
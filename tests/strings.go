@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func main() {
+	s := "  Hello, World  "
+	t := strings.TrimSpace(s)
+	fmt.Println(strings.ToUpper(t), strings.ToLower(t))
+	fmt.Println(strings.Contains(t, "World"), strings.HasPrefix(t, "He"), strings.HasSuffix(t, "d"))
+	fmt.Println(strings.Index(t, "o"), strings.Index(t, "z"))
+	parts := strings.Split("a,b,c", ",")
+	fmt.Println(len(parts), strings.Join(parts, "-"))
+	fmt.Println(strings.Repeat("ab", 3), strings.ReplaceAll(t, "l", "L"))
+	fmt.Println(strings.Fields(" x  y z "), strings.Count("cheese", "e"))
+	fmt.Println(strings.Replace("aaa", "a", "b", 2), strings.Trim("xxhixx", "x"))
+}
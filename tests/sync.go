@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Counter struct {
+	mu sync.Mutex
+	n  map[string]int
+}
+
+func (c *Counter) Inc(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n[key]++
+}
+
+func worker(id int, jobs <-chan int, results chan<- int, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for j := range jobs {
+		results <- j * id
+	}
+}
+
+func main() {
+	c := Counter{n: map[string]int{}}
+	for i := 0; i < 3; i++ {
+		c.Inc("a")
+	}
+	fmt.Println(c.n["a"])
+
+	var once sync.Once
+	setup := func() { fmt.Println("setup") }
+	for i := 0; i < 2; i++ {
+		once.Do(setup)
+	}
+
+	const numJobs = 4
+	jobs := make(chan int, numJobs)
+	results := make(chan int, numJobs)
+	var wg sync.WaitGroup
+	for w := 1; w <= 2; w++ {
+		wg.Add(1)
+		go worker(w, jobs, results, &wg)
+	}
+	for j := 1; j <= numJobs; j++ {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+	total := 0
+	for r := range results {
+		total += r
+	}
+	fmt.Println(total > 0)
+}
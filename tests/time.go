@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func main() {
+	start := time.Now()
+	d := 1500 * time.Millisecond
+	fmt.Println(d, d.Seconds(), time.Duration(2)*time.Second)
+	time.Sleep(10 * time.Millisecond)
+	elapsed := time.Since(start)
+	fmt.Println(elapsed > 0)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		<-ticker.C
+		fmt.Println("tick", i)
+	}
+	ticker.Stop()
+	<-time.After(5 * time.Millisecond)
+	reply := make(chan string)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		reply <- "reply"
+	}()
+	select {
+	case r := <-reply:
+		fmt.Println(r)
+	case <-time.After(10 * time.Millisecond):
+		fmt.Println("timeout")
+	}
+	fmt.Println(d.Milliseconds(), time.Minute.Minutes())
+}
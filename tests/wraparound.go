@@ -0,0 +1,27 @@
+// golang2evy: -wrap
+package main
+
+import "fmt"
+
+func hash(s string) uint32 {
+	h := uint32(2166136261)
+	for _, c := range []byte(s) {
+		h ^= uint32(c)
+		h *= 16777619
+	}
+	return h
+}
+
+func main() {
+	var b uint8 = 250
+	b += 10
+	var i int32 = 2147483647
+	i++
+	var u uint32 = 4000000000
+	u *= 3
+	var f float32 = 0.1
+	fmt.Println(b, i, u, f+0.2)
+	x := int8(200 - 100)
+	big := 70000
+	fmt.Println(x, uint16(big), hash("ab"))
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// Durations are translated to seconds, the unit of Evy's sleep, so a
+// time.Duration of d nanoseconds becomes d / 1e9. Constants are folded to
+// seconds, see foldConst, and conversions, multiplication and division
+// rescale where Go treats a duration as a count of nanoseconds. Times are
+// the seconds Evy's now returns.
+//
+// Tickers and timers are their interval: receiving from one sleeps for
+// it, and ranging over one becomes a loop sleeping before every
+// iteration. Sleeping suspends every goroutine, not only the one sleeping.
+
+// timeTypeName returns the name of a type of package time, looking
+// through pointers, or "".
+func timeTypeName(t types.Type) string {
+	named, ok := types.Unalias(deref(t)).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "time" {
+		return ""
+	}
+	return named.Obj().Name()
+}
+
+func isDuration(t types.Type) bool {
+	return t != nil && timeTypeName(t) == "Duration"
+}
+
+// isTimeNum reports whether values of t are translated to a num of
+// seconds, although Go declares them as structs.
+func isTimeNum(t types.Type) bool {
+	switch timeTypeName(t) {
+	case "Time", "Ticker", "Timer":
+		return true
+	}
+	return false
+}
+
+// durationNs returns the integral nanoseconds of the translated duration d.
+func durationNs(d string) string {
+	return "(round (" + d + " * 1000000000))"
+}
+
+// translateDurationArith translates the arithmetic of two durations that
+// is not invariant under scaling them to seconds.
+func translateDurationArith(op token.Token, x, y string) (string, bool) {
+	switch op {
+	case token.MUL:
+		return x + " * " + y + " * 1000000000", true
+	case token.QUO:
+		return "(" + evyCall(useHelper("__idiv"), durationNs(x), durationNs(y)) + ") / 1000000000", true
+	case token.REM:
+		return "(" + evyCall(useHelper("__imod"), durationNs(x), durationNs(y)) + ") / 1000000000", true
+	}
+	return "", false
+}
+
+// translateDurationConversion translates converting a number to a
+// duration, a count of nanoseconds, or back.
+func translateDurationConversion(info *types.Info, to, from types.Type, arg ast.Expr) string {
+	x := translateOperand(info, arg)
+	switch {
+	case isDuration(to) && isInteger(from):
+		return x + " / 1000000000"
+	case isDuration(to):
+		return "(" + evyCall(useHelper("__trunc"), x) + ") / 1000000000"
+	case isInteger(to):
+		return wrapValue(to, durationNs(x))
+	}
+	return wrapValue(to, x+" * 1000000000")
+}
+
+// timerInterval returns the interval of a ticker or timer channel:
+// ticker.C, time.Tick(d) or time.After(d). It returns nil for other
+// expressions.
+func timerInterval(info *types.Info, expr ast.Expr) ast.Expr {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.SelectorExpr:
+		if e.Sel.Name == "C" && isTimeNum(info.TypeOf(e.X)) {
+			return e.X
+		}
+	case *ast.CallExpr:
+		sel, ok := ast.Unparen(e.Fun).(*ast.SelectorExpr)
+		if !ok {
+			return nil
+		}
+		fn, ok := info.Uses[sel.Sel].(*types.Func)
+		if ok && fn.Pkg() != nil && fn.Pkg().Path() == "time" && (fn.Name() == "Tick" || fn.Name() == "After") {
+			return e.Args[0]
+		}
+	}
+	return nil
+}
+
+func isTimerRecv(info *types.Info, node ast.Node) bool {
+	u, ok := node.(*ast.UnaryExpr)
+	return ok && u.Op == token.ARROW && timerInterval(info, u.X) != nil
+}
+
+// translateTimerRecv translates receiving from a ticker or timer channel,
+// which sleeps and gives the time it woke up.
+func translateTimerRecv(info *types.Info, node *ast.UnaryExpr) (string, bool) {
+	interval := timerInterval(info, node.X)
+	if interval == nil {
+		return "", false
+	}
+	return "(" + evyCall(useHelper("__tick"), translateOperand(info, interval)) + ")", true
+}
+
+// translateTimerWait translates a receive from a ticker or timer channel
+// whose value is discarded.
+func translateTimerWait(info *types.Info, expr ast.Expr) (string, bool) {
+	u, ok := ast.Unparen(expr).(*ast.UnaryExpr)
+	if !ok || !isTimerRecv(info, u) {
+		return "", false
+	}
+	return evyCall("sleep", translateOperand(info, timerInterval(info, u.X))), true
+}
+
+// translateTimerRange translates ranging over a ticker channel to a loop
+// sleeping before every iteration.
+func translateTimerRange(info *types.Info, node *ast.RangeStmt) (string, bool) {
+	interval := timerInterval(info, node.X)
+	if interval == nil {
+		return "", false
+	}
+	var buf strings.Builder
+	d := translateOperand(info, interval)
+	if !isStable(info, interval, nil) {
+		tmp := newTemp("interval")
+		buf.WriteString(tmp + " := " + d + "\n")
+		d = tmp
+	}
+	buf.WriteString("while true\n")
+	buf.WriteString("    sleep " + d + "\n")
+	if node.Key != nil && !isBlank(node.Key) {
+		op := " = "
+		if node.Tok == token.DEFINE {
+			op = " := "
+		}
+		buf.WriteString("    " + translateLhs(info, node.Key) + op + "(now)\n")
+	}
+	writeBlock(&buf, translateBlockStmt(info, node.Body))
+	buf.WriteString("end")
+	return buf.String(), true
+}
+
+// translateTimeCall translates a call to a function of package time or a
+// method of one of its types.
+func translateTimeCall(info *types.Info, call *ast.CallExpr, name string) (string, bool) {
+	args := operands(info, call.Args)
+	var x string // the receiver of a method
+	if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok && info.Selections[sel] != nil {
+		x = translateOperand(info, sel.X)
+	}
+	switch name {
+	case "Sleep":
+		return evyCall("sleep", args[0]), true
+	case "Now":
+		return "(now)", true
+	case "Since":
+		return "((now) - " + args[0] + ")", true
+	case "Until":
+		return "(" + args[0] + " - (now))", true
+	case "Unix":
+		if x != "" {
+			return evyCall("floor", x), true
+		}
+		return "(" + args[0] + " + " + args[1] + " / 1000000000)", true
+	case "UnixMilli":
+		if x != "" {
+			return evyCall("floor", "("+x+" * 1000)"), true
+		}
+		return "(" + args[0] + " / 1000)", true
+	case "UnixNano", "Nanoseconds":
+		return durationNs(x), true
+	case "NewTicker", "NewTimer":
+		return args[0], true
+	case "Stop":
+		// A ticker only runs while it is received from.
+		return "", true
+	case "Reset":
+		sel := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+		return translateLhs(info, sel.X) + " = " + args[0], true
+	case "Sub", "Add":
+		op := map[string]string{"Sub": " - ", "Add": " + "}[name]
+		return "(" + x + op + args[0] + ")", true
+	case "Before", "After", "Equal":
+		if x == "" {
+			break // time.After outside a receive
+		}
+		op := map[string]string{"Before": " < ", "After": " > ", "Equal": " == "}[name]
+		return "(" + x + op + args[0] + ")", true
+	case "IsZero":
+		return "(" + x + " == 0)", true
+	case "Seconds":
+		return x, true
+	case "Minutes":
+		return "(" + x + " / 60)", true
+	case "Hours":
+		return "(" + x + " / 3600)", true
+	case "Milliseconds":
+		return evyCall(useHelper("__idiv"), durationNs(x), "1000000"), true
+	case "Microseconds":
+		return evyCall(useHelper("__idiv"), durationNs(x), "1000"), true
+	case "Truncate":
+		if timeTypeName(info.TypeOf(call)) == "Duration" {
+			return "((" + durationNs(x) + " - (" + evyCall(useHelper("__imod"), durationNs(x), durationNs(args[0])) + ")) / 1000000000)", true
+		}
+	case "Abs":
+		return evyCall(useHelper("__abs"), x), true
+	case "String":
+		if timeTypeName(info.TypeOf(call)) == "Duration" {
+			return evyCall(useHelper("__duration"), x), true
+		}
+	}
+	diagnose(call.Pos(), "time.%s is not supported", name)
+	return "", false
+}
+
+func init() {
+	for _, key := range []string{"time", "time.Duration", "time.Time", "time.Ticker", "time.Timer"} {
+		stdlibCalls[key] = translateTimeCall
+	}
+	addHelpers(map[string]helper{
+		"__tick": {src: `
+func __tick:num d:num
+    sleep d
+    return (now)
+end`},
+		// __duration formats a duration as time.Duration.String does.
+		"__duration": {src: `
+func __duration:string d:num
+    ns := round (d * 1000000000)
+    sign := ""
+    if ns < 0
+        sign = "-"
+        ns = -ns
+    end
+    if ns == 0
+        return "0s"
+    else if ns < 1000
+        return sprintf "%v%vns" sign ns
+    else if ns < 1000000
+        return sprintf "%v%vµs" sign (ns / 1000)
+    else if ns < 1000000000
+        return sprintf "%v%vms" sign (ns / 1000000)
+    end
+    h := floor (ns / 3600000000000)
+    m := floor ((ns - h * 3600000000000) / 60000000000)
+    s := (ns - h * 3600000000000 - m * 60000000000) / 1000000000
+    if h > 0
+        return sprintf "%v%vh%vm%vs" sign h m s
+    else if m > 0
+        return sprintf "%v%vm%vs" sign m s
+    end
+    return sprintf "%v%vs" sign s
+end`},
+	})
+}